@@ -0,0 +1,463 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	annotationNote      = "note"
+	annotationHighlight = "highlight"
+)
+
+type Annotation struct {
+	ID          string    `json:"id"`
+	ItemID      string    `json:"item_id"`
+	Kind        string    `json:"kind"`
+	Quote       *string   `json:"quote"`
+	Note        *string   `json:"note"`
+	StartOffset *int      `json:"start_offset"`
+	EndOffset   *int      `json:"end_offset"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type annotationRequest struct {
+	Kind        *string `json:"kind"`
+	Quote       *string `json:"quote"`
+	Note        *string `json:"note"`
+	StartOffset *int    `json:"start_offset"`
+	EndOffset   *int    `json:"end_offset"`
+}
+
+type annotationExportRow struct {
+	Annotation
+	FeedName    string
+	ItemTitle   string
+	ItemLink    string
+	ItemAuthor  *string
+	PublishedAt *time.Time
+}
+
+const annotationColumns = `id, item_id, kind, quote, note, start_offset, end_offset, created_at, updated_at`
+
+func scanAnnotation(scanner interface{ Scan(...interface{}) error }, extra ...interface{}) (Annotation, error) {
+	var annotation Annotation
+	var annotationID, itemID int64
+	targets := append([]interface{}{
+		&annotationID,
+		&itemID,
+		&annotation.Kind,
+		&annotation.Quote,
+		&annotation.Note,
+		&annotation.StartOffset,
+		&annotation.EndOffset,
+		&annotation.CreatedAt,
+		&annotation.UpdatedAt,
+	}, extra...)
+	if err := scanner.Scan(targets...); err != nil {
+		return annotation, err
+	}
+	annotation.ID = formatID(annotationID)
+	annotation.ItemID = formatID(itemID)
+	return annotation, nil
+}
+
+func (req annotationRequest) applyTo(annotation *Annotation) {
+	if req.Kind != nil {
+		annotation.Kind = strings.TrimSpace(*req.Kind)
+	}
+	if req.Quote != nil {
+		annotation.Quote = trimmedOptional(*req.Quote)
+	}
+	if req.Note != nil {
+		annotation.Note = trimmedOptional(*req.Note)
+	}
+	if req.StartOffset != nil {
+		annotation.StartOffset = req.StartOffset
+	}
+	if req.EndOffset != nil {
+		annotation.EndOffset = req.EndOffset
+	}
+}
+
+func trimmedOptional(value string) *string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func validateAnnotation(annotation Annotation, contentLength int) error {
+	switch annotation.Kind {
+	case annotationNote:
+		if annotation.Note == nil {
+			return errors.New("note is required")
+		}
+		if annotation.Quote != nil || annotation.StartOffset != nil || annotation.EndOffset != nil {
+			return errors.New("notes cannot have a quote or offsets")
+		}
+		return nil
+	case annotationHighlight:
+		if annotation.Quote == nil {
+			return errors.New("quote is required")
+		}
+	default:
+		return errors.New("kind must be note or highlight")
+	}
+
+	if (annotation.StartOffset == nil) != (annotation.EndOffset == nil) {
+		return errors.New("start_offset and end_offset must be given together")
+	}
+	if annotation.StartOffset == nil {
+		return nil
+	}
+	if *annotation.StartOffset < 0 || *annotation.EndOffset <= *annotation.StartOffset {
+		return errors.New("offsets must satisfy 0 <= start_offset < end_offset")
+	}
+	if *annotation.EndOffset > contentLength {
+		return fmt.Errorf("end_offset exceeds the item content length (%d)", contentLength)
+	}
+	return nil
+}
+
+func (s *Server) itemContentLength(ctx context.Context, itemID int64) (int, error) {
+	var content string
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(NULLIF(content, ''), summary, '') FROM items WHERE id = $1`, itemID).Scan(&content)
+	return utf8.RuneCountInString(content), err
+}
+
+func (s *Server) handleListAnnotations(c *gin.Context) {
+	itemID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+annotationColumns+`
+		FROM annotations
+		WHERE item_id = $1
+		ORDER BY start_offset ASC NULLS LAST, created_at ASC, id ASC
+	`, itemID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	annotations := make([]Annotation, 0)
+	for rows.Next() {
+		annotation, err := scanAnnotation(rows)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		annotations = append(annotations, annotation)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, annotations)
+}
+
+func (s *Server) handleCreateAnnotation(c *gin.Context) {
+	itemID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid item id")
+		return
+	}
+	var req annotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	contentLength, err := s.itemContentLength(ctx, itemID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	annotation := Annotation{Kind: annotationHighlight}
+	if req.Quote == nil && req.Note != nil {
+		annotation.Kind = annotationNote
+	}
+	req.applyTo(&annotation)
+	if err := validateAnnotation(annotation, contentLength); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	created, err := scanAnnotation(s.db.QueryRowContext(ctx, `
+		INSERT INTO annotations (item_id, kind, quote, note, start_offset, end_offset)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING `+annotationColumns,
+		itemID, annotation.Kind, annotation.Quote, annotation.Note, annotation.StartOffset, annotation.EndOffset,
+	))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusCreated, created)
+}
+
+func (s *Server) handleUpdateAnnotation(c *gin.Context) {
+	itemID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid item id")
+		return
+	}
+	annotationID, err := parseIDParam(c.Param("annotationID"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid annotation id")
+		return
+	}
+	var req annotationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	var contentLength int
+	annotation, err := scanAnnotation(s.db.QueryRowContext(ctx, `
+		SELECT `+annotationColumns+`, (SELECT char_length(COALESCE(NULLIF(i.content, ''), i.summary, '')) FROM items i WHERE i.id = a.item_id)
+		FROM annotations a
+		WHERE a.id = $1 AND a.item_id = $2
+	`, annotationID, itemID), &contentLength)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	req.applyTo(&annotation)
+	if err := validateAnnotation(annotation, contentLength); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	updated, err := scanAnnotation(s.db.QueryRowContext(ctx, `
+		UPDATE annotations
+		SET kind = $1, quote = $2, note = $3, start_offset = $4, end_offset = $5, updated_at = NOW()
+		WHERE id = $6
+		RETURNING `+annotationColumns,
+		annotation.Kind, annotation.Quote, annotation.Note, annotation.StartOffset, annotation.EndOffset, annotationID,
+	))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, updated)
+}
+
+func (s *Server) handleDeleteAnnotation(c *gin.Context) {
+	itemID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid item id")
+		return
+	}
+	annotationID, err := parseIDParam(c.Param("annotationID"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid annotation id")
+		return
+	}
+
+	result, err := s.db.Exec(`DELETE FROM annotations WHERE id = $1 AND item_id = $2`, annotationID, itemID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	respondSuccess(c, http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handleExportAnnotations(c *gin.Context) {
+	format := c.DefaultQuery("format", "markdown")
+	if format != "markdown" && format != "readwise" {
+		respondErrorMessage(c, http.StatusBadRequest, "format must be markdown or readwise")
+		return
+	}
+
+	conditions := []string{}
+	args := []interface{}{}
+	argIndex := 1
+	if value := c.Query("feed_id"); value != "" {
+		feedID, err := parseIDParam(value)
+		if err != nil {
+			respondErrorMessage(c, http.StatusBadRequest, "invalid feed id")
+			return
+		}
+		conditions = append(conditions, "i.feed_id = $"+strconv.Itoa(argIndex))
+		args = append(args, feedID)
+		argIndex++
+	}
+	since, err := parseFilterTime(c.Query("since"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid since")
+		return
+	}
+	if since != nil {
+		conditions = append(conditions, "a.created_at >= $"+strconv.Itoa(argIndex))
+		args = append(args, *since)
+		argIndex++
+	}
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	rows, err := s.db.Query(`
+		SELECT a.id, a.item_id, a.kind, a.quote, a.note, a.start_offset, a.end_offset, a.created_at, a.updated_at,
+			f.name, i.title, i.link, i.author, i.published_at
+		FROM annotations a
+		JOIN items i ON i.id = a.item_id
+		JOIN feeds f ON f.id = i.feed_id
+		`+whereClause+`
+		ORDER BY f.name ASC, f.id ASC, i.published_at ASC NULLS LAST, i.id ASC, a.start_offset ASC NULLS LAST, a.created_at ASC, a.id ASC
+	`, args...)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	exportRows := make([]annotationExportRow, 0)
+	for rows.Next() {
+		var row annotationExportRow
+		annotation, err := scanAnnotation(rows, &row.FeedName, &row.ItemTitle, &row.ItemLink, &row.ItemAuthor, &row.PublishedAt)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		row.Annotation = annotation
+		exportRows = append(exportRows, row)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	date := time.Now().UTC().Format("2006-01-02")
+	if format == "readwise" {
+		encoded, err := readwiseCSV(exportRows)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		c.Header("Content-Disposition", `attachment; filename="to-reads-annotations-`+date+`.csv"`)
+		c.Data(http.StatusOK, "text/csv; charset=utf-8", encoded)
+		return
+	}
+	c.Header("Content-Disposition", `attachment; filename="to-reads-annotations-`+date+`.md"`)
+	c.Data(http.StatusOK, "text/markdown; charset=utf-8", annotationsMarkdown(exportRows))
+}
+
+func annotationsMarkdown(rows []annotationExportRow) []byte {
+	var buffer bytes.Buffer
+	buffer.WriteString("# Annotations\n")
+	lastFeed := ""
+	lastItem := ""
+	for index, row := range rows {
+		if index == 0 || row.FeedName != lastFeed {
+			fmt.Fprintf(&buffer, "\n## %s\n", markdownLine(row.FeedName))
+			lastFeed = row.FeedName
+			lastItem = ""
+		}
+		if row.ItemID != lastItem {
+			fmt.Fprintf(&buffer, "\n### [%s](%s)\n", markdownLine(row.ItemTitle), row.ItemLink)
+			if row.PublishedAt != nil {
+				fmt.Fprintf(&buffer, "\n_Published %s_\n", row.PublishedAt.UTC().Format("2006-01-02"))
+			}
+			lastItem = row.ItemID
+		}
+		buffer.WriteString("\n")
+		if row.Quote != nil {
+			for _, line := range strings.Split(*row.Quote, "\n") {
+				buffer.WriteString(strings.TrimRight("> "+line, " ") + "\n")
+			}
+		}
+		if row.Note != nil {
+			if row.Quote != nil {
+				buffer.WriteString("\n")
+			}
+			buffer.WriteString(*row.Note + "\n")
+		}
+	}
+	return buffer.Bytes()
+}
+
+func markdownLine(value string) string {
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func readwiseCSV(rows []annotationExportRow) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	if err := writer.Write([]string{"Highlight", "Title", "Author", "URL", "Note", "Location", "Date"}); err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		highlight := ""
+		note := ""
+		if row.Quote != nil {
+			highlight = *row.Quote
+			if row.Note != nil {
+				note = *row.Note
+			}
+		} else if row.Note != nil {
+			highlight = *row.Note
+		}
+		author := ""
+		if row.ItemAuthor != nil {
+			author = *row.ItemAuthor
+		}
+		location := ""
+		if row.StartOffset != nil {
+			location = strconv.Itoa(*row.StartOffset)
+		}
+		if err := writer.Write([]string{
+			highlight,
+			row.ItemTitle,
+			author,
+			row.ItemLink,
+			note,
+			location,
+			row.CreatedAt.UTC().Format("2006-01-02 15:04:05 -0700"),
+		}); err != nil {
+			return nil, err
+		}
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
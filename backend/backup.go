@@ -0,0 +1,746 @@
+package main
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const backupVersion = 2
+
+type backupEnvelope struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+type backupHeader struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type backupFooter struct {
+	Records int `json:"records"`
+}
+
+type backupCategory struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	ParentID  *string   `json:"parent_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type backupFeed struct {
+	ID            string     `json:"id"`
+	Name          string     `json:"name"`
+	URL           string     `json:"url"`
+	SiteURL       *string    `json:"site_url"`
+	Language      *string    `json:"language"`
+	CategoryID    *string    `json:"category_id"`
+	FetchInterval int        `json:"fetch_interval_minutes"`
+	LastFetchedAt *time.Time `json:"last_fetched_at"`
+	LastStatus    *string    `json:"last_status"`
+	LastError     *string    `json:"last_error"`
+	LastWarning   *string    `json:"last_warning"`
+	FetchCount    int        `json:"fetch_count"`
+	FetchErrors   int        `json:"fetch_error_count"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+type backupTag struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type backupItem struct {
+	FeedID      string          `json:"feed_id"`
+	GUID        string          `json:"guid"`
+	Title       string          `json:"title"`
+	Link        string          `json:"link"`
+	Summary     *string         `json:"summary"`
+	Content     *string         `json:"content"`
+	Author      *string         `json:"author"`
+	Enclosures  json.RawMessage `json:"enclosures"`
+	PublishedAt *time.Time      `json:"published_at"`
+	IsRead      bool            `json:"is_read"`
+	IsFavorite  bool            `json:"is_favorite"`
+	ReadAt      *time.Time      `json:"read_at,omitempty"`
+	ClickCount  int             `json:"click_count,omitempty"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ReadLaterAt *time.Time      `json:"read_later_at"`
+	Tags        []string        `json:"tags,omitempty"`
+}
+
+type backupAnnotation struct {
+	FeedID      string    `json:"feed_id"`
+	GUID        string    `json:"guid"`
+	Kind        string    `json:"kind"`
+	Quote       *string   `json:"quote"`
+	Note        *string   `json:"note"`
+	StartOffset *int      `json:"start_offset"`
+	EndOffset   *int      `json:"end_offset"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type RestoreReport struct {
+	Mode          string `json:"mode"`
+	Categories    int    `json:"categories"`
+	Feeds         int    `json:"feeds"`
+	Tags          int    `json:"tags"`
+	Items         int    `json:"items"`
+	ReadLater     int    `json:"read_later"`
+	SavedSearches int    `json:"saved_searches"`
+	Rules         int    `json:"rules"`
+	Annotations   int    `json:"annotations"`
+	SkippedItems  int    `json:"skipped_items"`
+}
+
+type backupWriter struct {
+	encoder *json.Encoder
+	records int
+}
+
+func (w *backupWriter) write(recordType string, data interface{}) error {
+	encoded, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if err := w.encoder.Encode(backupEnvelope{Type: recordType, Data: encoded}); err != nil {
+		return err
+	}
+	w.records++
+	return nil
+}
+
+func (s *Server) handleBackup(c *gin.Context) {
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	filename := fmt.Sprintf("to-reads-backup-%s.jsonl.gz", time.Now().UTC().Format("2006-01-02"))
+	c.Header("Content-Type", "application/gzip")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	gzipWriter := gzip.NewWriter(c.Writer)
+	if err := s.writeBackup(c.Request.Context(), &backupWriter{encoder: json.NewEncoder(gzipWriter)}); err != nil {
+		log.Printf("write backup: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		log.Printf("close backup: %v", err)
+		panic(http.ErrAbortHandler)
+	}
+}
+
+func (s *Server) writeBackup(ctx context.Context, writer *backupWriter) error {
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if err := writer.write("header", backupHeader{Version: backupVersion, CreatedAt: time.Now().UTC()}); err != nil {
+		return err
+	}
+
+	categoryRows, err := tx.QueryContext(ctx, `SELECT id, name, parent_id, created_at FROM categories ORDER BY id ASC`)
+	if err != nil {
+		return err
+	}
+	defer categoryRows.Close()
+	for categoryRows.Next() {
+		var category backupCategory
+		var categoryID int64
+		var parentID sql.NullInt64
+		if err := categoryRows.Scan(&categoryID, &category.Name, &parentID, &category.CreatedAt); err != nil {
+			return err
+		}
+		category.ID = formatID(categoryID)
+		category.ParentID = formatNullableID(parentID)
+		if err := writer.write("category", category); err != nil {
+			return err
+		}
+	}
+	if err := categoryRows.Err(); err != nil {
+		return err
+	}
+
+	feedRows, err := tx.QueryContext(ctx, `
+		SELECT id, name, url, site_url, language, category_id, fetch_interval_minutes, last_fetched_at, last_status, last_error, last_warning,
+			fetch_count, fetch_error_count, created_at
+		FROM feeds
+		ORDER BY id ASC
+	`)
+	if err != nil {
+		return err
+	}
+	defer feedRows.Close()
+	for feedRows.Next() {
+		var feed backupFeed
+		var feedID int64
+		var categoryID sql.NullInt64
+		if err := feedRows.Scan(
+			&feedID,
+			&feed.Name,
+			&feed.URL,
+			&feed.SiteURL,
+			&feed.Language,
+			&categoryID,
+			&feed.FetchInterval,
+			&feed.LastFetchedAt,
+			&feed.LastStatus,
+			&feed.LastError,
+			&feed.LastWarning,
+			&feed.FetchCount,
+			&feed.FetchErrors,
+			&feed.CreatedAt,
+		); err != nil {
+			return err
+		}
+		feed.ID = formatID(feedID)
+		feed.CategoryID = formatNullableID(categoryID)
+		if err := writer.write("feed", feed); err != nil {
+			return err
+		}
+	}
+	if err := feedRows.Err(); err != nil {
+		return err
+	}
+
+	tagRows, err := tx.QueryContext(ctx, `SELECT name, created_at FROM tags ORDER BY id ASC`)
+	if err != nil {
+		return err
+	}
+	defer tagRows.Close()
+	for tagRows.Next() {
+		var tag backupTag
+		if err := tagRows.Scan(&tag.Name, &tag.CreatedAt); err != nil {
+			return err
+		}
+		if err := writer.write("tag", tag); err != nil {
+			return err
+		}
+	}
+	if err := tagRows.Err(); err != nil {
+		return err
+	}
+
+	savedSearches, err := listSavedSearches(ctx, tx)
+	if err != nil {
+		return err
+	}
+	for _, savedSearch := range savedSearches {
+		if err := writer.write("saved_search", savedSearch); err != nil {
+			return err
+		}
+	}
+
+	rules, err := listRules(ctx, tx, false)
+	if err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		if err := writer.write("rule", rule); err != nil {
+			return err
+		}
+	}
+
+	itemRows, err := tx.QueryContext(ctx, `
+		SELECT i.feed_id, i.guid, i.title, i.link, i.summary, i.content, i.author, i.enclosures, i.published_at,
+			i.is_read, i.is_favorite, i.read_at, i.click_count, i.created_at, rl.created_at, `+itemTagNamesColumn+`
+		FROM items i
+		LEFT JOIN read_later rl ON rl.item_id = i.id
+		ORDER BY i.id ASC
+	`)
+	if err != nil {
+		return err
+	}
+	defer itemRows.Close()
+	for itemRows.Next() {
+		var item backupItem
+		var feedID int64
+		var enclosures []byte
+		if err := itemRows.Scan(
+			&feedID,
+			&item.GUID,
+			&item.Title,
+			&item.Link,
+			&item.Summary,
+			&item.Content,
+			&item.Author,
+			&enclosures,
+			&item.PublishedAt,
+			&item.IsRead,
+			&item.IsFavorite,
+			&item.ReadAt,
+			&item.ClickCount,
+			&item.CreatedAt,
+			&item.ReadLaterAt,
+			pq.Array(&item.Tags),
+		); err != nil {
+			return err
+		}
+		item.FeedID = formatID(feedID)
+		item.Enclosures = json.RawMessage(enclosures)
+		if err := writer.write("item", item); err != nil {
+			return err
+		}
+	}
+	if err := itemRows.Err(); err != nil {
+		return err
+	}
+
+	annotationRows, err := tx.QueryContext(ctx, `
+		SELECT i.feed_id, i.guid, a.kind, a.quote, a.note, a.start_offset, a.end_offset, a.created_at, a.updated_at
+		FROM annotations a
+		JOIN items i ON i.id = a.item_id
+		ORDER BY a.id ASC
+	`)
+	if err != nil {
+		return err
+	}
+	defer annotationRows.Close()
+	for annotationRows.Next() {
+		var annotation backupAnnotation
+		var feedID int64
+		if err := annotationRows.Scan(
+			&feedID,
+			&annotation.GUID,
+			&annotation.Kind,
+			&annotation.Quote,
+			&annotation.Note,
+			&annotation.StartOffset,
+			&annotation.EndOffset,
+			&annotation.CreatedAt,
+			&annotation.UpdatedAt,
+		); err != nil {
+			return err
+		}
+		annotation.FeedID = formatID(feedID)
+		if err := writer.write("annotation", annotation); err != nil {
+			return err
+		}
+	}
+	if err := annotationRows.Err(); err != nil {
+		return err
+	}
+	if err := writer.write("end", backupFooter{Records: writer.records}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *Server) handleRestore(c *gin.Context) {
+	_ = http.NewResponseController(c.Writer).SetReadDeadline(time.Time{})
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{})
+
+	mode := c.DefaultQuery("mode", "merge")
+	if mode != "merge" && mode != "replace" {
+		respondErrorMessage(c, http.StatusBadRequest, "mode must be merge or replace")
+		return
+	}
+
+	reader := bufio.NewReader(c.Request.Body)
+	var source io.Reader = reader
+	if magic, err := reader.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gzipReader, err := gzip.NewReader(reader)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		defer gzipReader.Close()
+		source = gzipReader
+	}
+
+	report, err := s.restoreBackup(c.Request.Context(), json.NewDecoder(source), mode)
+	if err != nil {
+		var restoreErr restoreError
+		if errors.As(err, &restoreErr) {
+			respondError(c, http.StatusBadRequest, err)
+			return
+		}
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respondSuccess(c, http.StatusOK, report)
+}
+
+type restoreError struct {
+	message string
+}
+
+func (e restoreError) Error() string {
+	return e.message
+}
+
+func (s *Server) restoreBackup(ctx context.Context, decoder *json.Decoder, mode string) (RestoreReport, error) {
+	report := RestoreReport{Mode: mode}
+
+	var envelope backupEnvelope
+	if err := decoder.Decode(&envelope); err != nil {
+		return report, restoreError{message: fmt.Sprintf("read backup header: %v", err)}
+	}
+	var header backupHeader
+	if envelope.Type != "header" || json.Unmarshal(envelope.Data, &header) != nil {
+		return report, restoreError{message: "backup must start with a header record"}
+	}
+	if header.Version < 1 || header.Version > backupVersion {
+		return report, restoreError{message: fmt.Sprintf("unsupported backup version %d", header.Version)}
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if mode == "replace" {
+		if _, err := tx.ExecContext(ctx, `TRUNCATE operation_items, operations, annotations, read_later, item_tags, tags, rules, saved_searches, feed_counters, items, feeds, categories RESTART IDENTITY CASCADE`); err != nil {
+			return report, err
+		}
+	}
+
+	itemStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO items (feed_id, title, link, summary, content, enclosures, guid, published_at, is_read, is_favorite, created_at, author, read_at, click_count, search_vector)
+		SELECT $1::bigint, $2::text, $3::text, $4::text, $5::text, $6::jsonb, $7::text, $8::timestamptz, $9::boolean, $10::boolean, $11::timestamptz, $12::text, $13::timestamptz, $14::integer, `+itemSearchVector("f.search_config", "$2::text", "$4::text")+`
+		FROM feeds f
+		WHERE f.id = $1
+		ON CONFLICT (feed_id, guid) DO UPDATE SET
+			is_read = items.is_read OR EXCLUDED.is_read,
+			is_favorite = items.is_favorite OR EXCLUDED.is_favorite,
+			read_at = COALESCE(items.read_at, EXCLUDED.read_at),
+			click_count = GREATEST(items.click_count, EXCLUDED.click_count)
+		RETURNING id
+	`)
+	if err != nil {
+		return report, err
+	}
+	defer itemStmt.Close()
+
+	readLaterStmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO read_later (item_id, created_at)
+		VALUES ($1, $2)
+		ON CONFLICT (item_id) DO NOTHING
+	`)
+	if err != nil {
+		return report, err
+	}
+	defer readLaterStmt.Close()
+
+	categoryIDs := make(map[string]int64)
+	categoryParents := make(map[string]string)
+	feedIDs := make(map[string]int64)
+	records := 1
+	ended := false
+	for !ended {
+		var envelope backupEnvelope
+		if err := decoder.Decode(&envelope); err != nil {
+			if errors.Is(err, io.EOF) {
+				if header.Version >= 2 {
+					return report, restoreError{message: "backup is truncated: missing end record"}
+				}
+				break
+			}
+			return report, restoreError{message: fmt.Sprintf("read backup: %v", err)}
+		}
+		if envelope.Type == "end" {
+			var footer backupFooter
+			if err := json.Unmarshal(envelope.Data, &footer); err != nil || footer.Records != records {
+				return report, restoreError{message: fmt.Sprintf("backup is incomplete: read %d records before the end record", records)}
+			}
+			ended = true
+			continue
+		}
+		records++
+
+		switch envelope.Type {
+		case "category":
+			var category backupCategory
+			if err := json.Unmarshal(envelope.Data, &category); err != nil {
+				return report, restoreError{message: fmt.Sprintf("invalid category record: %v", err)}
+			}
+			var categoryID int64
+			if err := tx.QueryRowContext(ctx, `
+				INSERT INTO categories (name, created_at) VALUES ($1, $2)
+				ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+				RETURNING id
+			`, category.Name, category.CreatedAt).Scan(&categoryID); err != nil {
+				return report, err
+			}
+			categoryIDs[category.ID] = categoryID
+			if category.ParentID != nil {
+				categoryParents[category.ID] = *category.ParentID
+			}
+			report.Categories++
+		case "feed":
+			var feed backupFeed
+			if err := json.Unmarshal(envelope.Data, &feed); err != nil {
+				return report, restoreError{message: fmt.Sprintf("invalid feed record: %v", err)}
+			}
+			var categoryID *int64
+			if feed.CategoryID != nil {
+				if mappedID, ok := categoryIDs[*feed.CategoryID]; ok {
+					categoryID = &mappedID
+				}
+			}
+			if feed.FetchInterval <= 0 {
+				feed.FetchInterval = s.config.FetchIntervalMinutes
+			}
+			searchConfig := "simple"
+			if feed.Language != nil {
+				searchConfig = textSearchConfig(*feed.Language)
+			}
+			var feedID int64
+			if err := tx.QueryRowContext(ctx, `
+				INSERT INTO feeds (name, url, site_url, language, search_config, category_id, fetch_interval_minutes, last_fetched_at, last_status, last_error, last_warning, fetch_count, fetch_error_count, created_at)
+				VALUES ($1, $2, $3, $4, $5::regconfig, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+				ON CONFLICT (url) DO UPDATE SET url = EXCLUDED.url
+				RETURNING id
+			`,
+				feed.Name,
+				feed.URL,
+				feed.SiteURL,
+				feed.Language,
+				searchConfig,
+				categoryID,
+				feed.FetchInterval,
+				feed.LastFetchedAt,
+				feed.LastStatus,
+				feed.LastError,
+				feed.LastWarning,
+				feed.FetchCount,
+				feed.FetchErrors,
+				feed.CreatedAt,
+			).Scan(&feedID); err != nil {
+				return report, err
+			}
+			feedIDs[feed.ID] = feedID
+			report.Feeds++
+		case "tag":
+			var tag backupTag
+			if err := json.Unmarshal(envelope.Data, &tag); err != nil {
+				return report, restoreError{message: fmt.Sprintf("invalid tag record: %v", err)}
+			}
+			result, err := tx.ExecContext(ctx, `
+				INSERT INTO tags (name, created_at) VALUES ($1, $2)
+				ON CONFLICT (name) DO NOTHING
+			`, tag.Name, tag.CreatedAt)
+			if err != nil {
+				return report, err
+			}
+			count, _ := result.RowsAffected()
+			report.Tags += int(count)
+		case "saved_search":
+			var savedSearch SavedSearch
+			if err := json.Unmarshal(envelope.Data, &savedSearch); err != nil {
+				return report, restoreError{message: fmt.Sprintf("invalid saved search record: %v", err)}
+			}
+			var categoryID, feedID *int64
+			if savedSearch.CategoryID != nil {
+				mappedID, ok := categoryIDs[*savedSearch.CategoryID]
+				if !ok {
+					continue
+				}
+				categoryID = &mappedID
+			}
+			if savedSearch.FeedID != nil {
+				mappedID, ok := feedIDs[*savedSearch.FeedID]
+				if !ok {
+					continue
+				}
+				feedID = &mappedID
+			}
+			result, err := tx.ExecContext(ctx, `
+				INSERT INTO saved_searches (name, category_id, feed_id, tag, query, unread_only, favorite_only, since, until, date_field, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, COALESCE(NULLIF($10, ''), 'published'), $11)
+				ON CONFLICT (name) DO NOTHING
+			`,
+				savedSearch.Name,
+				categoryID,
+				feedID,
+				savedSearch.Tag,
+				savedSearch.Query,
+				savedSearch.Unread,
+				savedSearch.Favorite,
+				savedSearch.Since,
+				savedSearch.Until,
+				savedSearch.DateField,
+				savedSearch.CreatedAt,
+			)
+			if err != nil {
+				return report, err
+			}
+			count, _ := result.RowsAffected()
+			report.SavedSearches += int(count)
+		case "rule":
+			var rule Rule
+			if err := json.Unmarshal(envelope.Data, &rule); err != nil {
+				return report, restoreError{message: fmt.Sprintf("invalid rule record: %v", err)}
+			}
+			var categoryID, feedID *int64
+			if rule.CategoryID != nil {
+				mappedID, ok := categoryIDs[*rule.CategoryID]
+				if !ok {
+					continue
+				}
+				categoryID = &mappedID
+			}
+			if rule.FeedID != nil {
+				mappedID, ok := feedIDs[*rule.FeedID]
+				if !ok {
+					continue
+				}
+				feedID = &mappedID
+			}
+			conditions, err := json.Marshal(rule.Conditions)
+			if err != nil {
+				return report, err
+			}
+			actions, err := json.Marshal(rule.Actions)
+			if err != nil {
+				return report, err
+			}
+			result, err := tx.ExecContext(ctx, `
+				INSERT INTO rules (name, enabled, feed_id, category_id, match_mode, conditions, actions, created_at)
+				SELECT $1::text, $2::boolean, $3::bigint, $4::bigint, $5::text, $6::jsonb, $7::jsonb, $8::timestamptz
+				WHERE NOT EXISTS (SELECT 1 FROM rules WHERE name = $1::text)
+			`,
+				rule.Name,
+				rule.Enabled,
+				feedID,
+				categoryID,
+				rule.Match,
+				string(conditions),
+				string(actions),
+				rule.CreatedAt,
+			)
+			if err != nil {
+				return report, err
+			}
+			count, _ := result.RowsAffected()
+			report.Rules += int(count)
+		case "item":
+			var item backupItem
+			if err := json.Unmarshal(envelope.Data, &item); err != nil {
+				return report, restoreError{message: fmt.Sprintf("invalid item record: %v", err)}
+			}
+			feedID, ok := feedIDs[item.FeedID]
+			if !ok || item.GUID == "" {
+				report.SkippedItems++
+				continue
+			}
+			enclosures := "[]"
+			if len(item.Enclosures) > 0 && string(item.Enclosures) != "null" {
+				enclosures = string(item.Enclosures)
+			}
+			var itemID int64
+			if err := itemStmt.QueryRowContext(ctx,
+				feedID,
+				item.Title,
+				item.Link,
+				item.Summary,
+				item.Content,
+				enclosures,
+				item.GUID,
+				item.PublishedAt,
+				item.IsRead,
+				item.IsFavorite,
+				item.CreatedAt,
+				item.Author,
+				item.ReadAt,
+				item.ClickCount,
+			).Scan(&itemID); err != nil {
+				return report, err
+			}
+			report.Items++
+			if item.ReadLaterAt != nil {
+				if _, err := readLaterStmt.ExecContext(ctx, itemID, *item.ReadLaterAt); err != nil {
+					return report, err
+				}
+				report.ReadLater++
+			}
+			if len(item.Tags) > 0 {
+				if _, err := attachTags(ctx, tx, []int64{itemID}, item.Tags); err != nil {
+					return report, err
+				}
+			}
+		case "annotation":
+			var annotation backupAnnotation
+			if err := json.Unmarshal(envelope.Data, &annotation); err != nil {
+				return report, restoreError{message: fmt.Sprintf("invalid annotation record: %v", err)}
+			}
+			feedID, ok := feedIDs[annotation.FeedID]
+			if !ok {
+				continue
+			}
+			result, err := tx.ExecContext(ctx, `
+				INSERT INTO annotations (item_id, kind, quote, note, start_offset, end_offset, created_at, updated_at)
+				SELECT i.id, $3::text, $4::text, $5::text, $6::integer, $7::integer, $8::timestamptz, $9::timestamptz
+				FROM items i
+				WHERE i.feed_id = $1 AND i.guid = $2
+				  AND NOT EXISTS (
+					SELECT 1 FROM annotations a
+					WHERE a.item_id = i.id AND a.kind = $3::text AND a.created_at = $8::timestamptz
+				  )
+			`,
+				feedID,
+				annotation.GUID,
+				annotation.Kind,
+				annotation.Quote,
+				annotation.Note,
+				annotation.StartOffset,
+				annotation.EndOffset,
+				annotation.CreatedAt,
+				annotation.UpdatedAt,
+			)
+			if err != nil {
+				return report, err
+			}
+			count, _ := result.RowsAffected()
+			report.Annotations += int(count)
+		default:
+			return report, restoreError{message: fmt.Sprintf("unknown backup record type %q", envelope.Type)}
+		}
+	}
+
+	for backupID, backupParentID := range categoryParents {
+		categoryID, ok := categoryIDs[backupID]
+		parentID, parentOK := categoryIDs[backupParentID]
+		if !ok || !parentOK {
+			continue
+		}
+		cycle, err := isCategoryInTree(ctx, tx, categoryID, parentID)
+		if err != nil {
+			return report, err
+		}
+		if cycle {
+			continue
+		}
+		if _, err := tx.ExecContext(ctx, `UPDATE categories SET parent_id = $2 WHERE id = $1 AND parent_id IS NULL`, categoryID, parentID); err != nil {
+			return report, err
+		}
+	}
+	if _, _, err := recomputeFeedCounters(ctx, tx); err != nil {
+		return report, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
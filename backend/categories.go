@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type updateCategoryRequest struct {
+	Name     *string `json:"name"`
+	ParentID *string `json:"parent_id"`
+}
+
+type mergeCategoryRequest struct {
+	TargetID string `json:"target_id"`
+}
+
+func categoryTreeSQL(seed string) string {
+	return `WITH RECURSIVE category_tree(id) AS (` + seed + ` UNION SELECT c.id FROM categories c JOIN category_tree t ON c.parent_id = t.id) SELECT id FROM category_tree`
+}
+
+func categoryTreeCondition(seed string) string {
+	return "f.category_id IN (" + categoryTreeSQL(seed) + ")"
+}
+
+func isCategoryInTree(ctx context.Context, q dbExecutor, rootID int64, categoryID int64) (bool, error) {
+	var inTree bool
+	err := q.QueryRowContext(ctx, `SELECT $2::bigint IN (`+categoryTreeSQL("SELECT $1::bigint")+`)`, rootID, categoryID).Scan(&inTree)
+	return inTree, err
+}
+
+func categoryAncestors(ctx context.Context, q queryer, categoryID int64) ([]int64, error) {
+	rows, err := q.QueryContext(ctx, `
+		WITH RECURSIVE ancestors(id, parent_id) AS (
+			SELECT id, parent_id FROM categories WHERE id = $1
+			UNION
+			SELECT c.id, c.parent_id FROM categories c JOIN ancestors a ON c.id = a.parent_id
+		)
+		SELECT id FROM ancestors
+	`, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ancestors := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ancestors = append(ancestors, id)
+	}
+	return ancestors, rows.Err()
+}
+
+func moveCategoryContents(ctx context.Context, tx dbExecutor, sourceID int64, targetID int64) (int64, error) {
+	var movedFeeds int64
+	statements := []string{
+		`UPDATE feeds SET category_id = $2 WHERE category_id = $1`,
+		`UPDATE categories SET parent_id = $2 WHERE parent_id = $1`,
+		`UPDATE saved_searches SET category_id = $2 WHERE category_id = $1`,
+		`UPDATE rules SET category_id = $2 WHERE category_id = $1`,
+	}
+	for index, statement := range statements {
+		result, err := tx.ExecContext(ctx, statement, sourceID, targetID)
+		if err != nil {
+			return 0, err
+		}
+		if index == 0 {
+			movedFeeds, _ = result.RowsAffected()
+		}
+	}
+	return movedFeeds, nil
+}
+
+func (s *Server) loadCategory(ctx context.Context, id int64) (Category, error) {
+	var category Category
+	var categoryID int64
+	var parentID sql.NullInt64
+	err := s.db.QueryRowContext(ctx, `SELECT id, name, parent_id, created_at FROM categories WHERE id = $1`, id).
+		Scan(&categoryID, &category.Name, &parentID, &category.CreatedAt)
+	category.ID = formatID(categoryID)
+	category.ParentID = formatNullableID(parentID)
+	return category, err
+}
+
+func (s *Server) validateCategoryParent(c *gin.Context, categoryID int64, value *string) (*int64, bool) {
+	parentID, err := parseOptionalID(value)
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid parent id")
+		return nil, false
+	}
+	if parentID == nil || *parentID == 0 {
+		return nil, true
+	}
+
+	ctx := c.Request.Context()
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, *parentID).Scan(&exists); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return nil, false
+	}
+	if !exists {
+		respondErrorMessage(c, http.StatusBadRequest, "parent category not found")
+		return nil, false
+	}
+	if categoryID != 0 {
+		cycle, err := isCategoryInTree(ctx, s.db, categoryID, *parentID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return nil, false
+		}
+		if cycle {
+			respondErrorMessage(c, http.StatusBadRequest, "a category cannot be nested under itself or its subcategories")
+			return nil, false
+		}
+	}
+	return parentID, true
+}
+
+func (s *Server) handleUpdateCategory(c *gin.Context) {
+	categoryID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid category id")
+		return
+	}
+
+	var req updateCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	setClauses := []string{}
+	args := []interface{}{}
+	argIndex := 1
+	if req.Name != nil {
+		trimmed := strings.TrimSpace(*req.Name)
+		if trimmed == "" {
+			respondErrorMessage(c, http.StatusBadRequest, "name cannot be empty")
+			return
+		}
+		setClauses = append(setClauses, "name = $"+strconv.Itoa(argIndex))
+		args = append(args, trimmed)
+		argIndex++
+	}
+	if req.ParentID != nil {
+		parentID, ok := s.validateCategoryParent(c, categoryID, req.ParentID)
+		if !ok {
+			return
+		}
+		if parentID == nil {
+			setClauses = append(setClauses, "parent_id = NULL")
+		} else {
+			setClauses = append(setClauses, "parent_id = $"+strconv.Itoa(argIndex))
+			args = append(args, *parentID)
+			argIndex++
+		}
+	}
+	if len(setClauses) == 0 {
+		respondErrorMessage(c, http.StatusBadRequest, "no fields to update")
+		return
+	}
+
+	args = append(args, categoryID)
+	result, err := s.db.Exec(`UPDATE categories SET `+strings.Join(setClauses, ", ")+` WHERE id = $`+strconv.Itoa(argIndex), args...)
+	if isUniqueViolation(err) {
+		respondErrorMessage(c, http.StatusConflict, "category name already exists")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	category, err := s.loadCategory(c.Request.Context(), categoryID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, category)
+}
+
+func (s *Server) handleMergeCategory(c *gin.Context) {
+	sourceID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid category id")
+		return
+	}
+	var req mergeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	targetID, err := parseIDParam(strings.TrimSpace(req.TargetID))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid target id")
+		return
+	}
+	if targetID == sourceID {
+		respondErrorMessage(c, http.StatusBadRequest, "cannot merge a category into itself")
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var found int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM categories WHERE id IN ($1, $2)`, sourceID, targetID).Scan(&found); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if found != 2 {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	nested, err := isCategoryInTree(ctx, tx, sourceID, targetID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if nested {
+		respondErrorMessage(c, http.StatusBadRequest, "cannot merge a category into its own subcategory")
+		return
+	}
+
+	movedFeeds, err := moveCategoryContents(ctx, tx, sourceID, targetID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, sourceID); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	category, err := s.loadCategory(ctx, targetID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, gin.H{"category": category, "moved_feeds": movedFeeds})
+}
+
+func (s *Server) handleDeleteCategory(c *gin.Context) {
+	categoryID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid category id")
+		return
+	}
+	deleteFeeds := c.Query("delete_feeds") == "true"
+	var moveTo *int64
+	if value := strings.TrimSpace(c.Query("move_to")); value != "" {
+		parsed, err := parseIDParam(value)
+		if err != nil {
+			respondErrorMessage(c, http.StatusBadRequest, "invalid move_to id")
+			return
+		}
+		moveTo = &parsed
+	}
+	if moveTo != nil && deleteFeeds {
+		respondErrorMessage(c, http.StatusBadRequest, "move_to and delete_feeds cannot be combined")
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var parentID sql.NullInt64
+	var feedCount int64
+	err = tx.QueryRowContext(ctx, `
+		SELECT c.parent_id, (SELECT COUNT(*) FROM feeds f WHERE f.category_id = c.id)
+		FROM categories c
+		WHERE c.id = $1
+		FOR UPDATE
+	`, categoryID).Scan(&parentID, &feedCount)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	var affectedFeeds, deletedRules int64
+	switch {
+	case moveTo != nil:
+		if *moveTo == categoryID {
+			respondErrorMessage(c, http.StatusBadRequest, "cannot move feeds into the category being deleted")
+			return
+		}
+		var exists bool
+		if err := tx.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM categories WHERE id = $1)`, *moveTo).Scan(&exists); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if !exists {
+			respondErrorMessage(c, http.StatusBadRequest, "move_to category not found")
+			return
+		}
+		nested, err := isCategoryInTree(ctx, tx, categoryID, *moveTo)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		if nested {
+			respondErrorMessage(c, http.StatusBadRequest, "cannot move feeds into a subcategory of the category being deleted")
+			return
+		}
+		if affectedFeeds, err = moveCategoryContents(ctx, tx, categoryID, *moveTo); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+	case deleteFeeds:
+		result, err := tx.ExecContext(ctx, `DELETE FROM feeds WHERE category_id = $1`, categoryID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		affectedFeeds, _ = result.RowsAffected()
+	case feedCount > 0:
+		respondErrorData(c, http.StatusConflict, "category is not empty; pass move_to or delete_feeds=true", gin.H{"feeds": feedCount})
+		return
+	}
+
+	if moveTo == nil {
+		if _, err := tx.ExecContext(ctx, `UPDATE categories SET parent_id = $2 WHERE parent_id = $1`, categoryID, parentID); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		result, err := tx.ExecContext(ctx, `DELETE FROM rules WHERE category_id = $1`, categoryID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		deletedRules, _ = result.RowsAffected()
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM categories WHERE id = $1`, categoryID); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	respondSuccess(c, http.StatusOK, gin.H{"status": "ok", "affected_feeds": affectedFeeds, "deleted_rules": deletedRules})
+}
@@ -6,9 +6,12 @@ import (
 )
 
 type Config struct {
-	DatabaseURL          string
-	Port                 string
-	FetchIntervalMinutes int
+	DatabaseURL           string
+	Port                  string
+	FetchIntervalMinutes  int
+	RetentionDays         int
+	UndoWindowMinutes     int
+	CounterReconcileHours int
 }
 
 func LoadConfig() Config {
@@ -19,6 +22,27 @@ func LoadConfig() Config {
 		}
 	}
 
+	retentionDays := 0
+	if value := os.Getenv("RETENTION_DAYS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			retentionDays = parsed
+		}
+	}
+
+	undoWindow := 10
+	if value := os.Getenv("UNDO_WINDOW_MINUTES"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			undoWindow = parsed
+		}
+	}
+
+	counterReconcile := 24
+	if value := os.Getenv("COUNTER_RECONCILE_HOURS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			counterReconcile = parsed
+		}
+	}
+
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
@@ -30,8 +54,11 @@ func LoadConfig() Config {
 	}
 
 	return Config{
-		DatabaseURL:          databaseURL,
-		Port:                 port,
-		FetchIntervalMinutes: fetchInterval,
+		DatabaseURL:           databaseURL,
+		Port:                  port,
+		FetchIntervalMinutes:  fetchInterval,
+		RetentionDays:         retentionDays,
+		UndoWindowMinutes:     undoWindow,
+		CounterReconcileHours: counterReconcile,
 	}
 }
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+type Counter struct {
+	Unread  int64 `json:"unread"`
+	Starred int64 `json:"starred"`
+	Total   int64 `json:"total"`
+}
+
+type FeedCounter struct {
+	FeedID string `json:"feed_id"`
+	Counter
+}
+
+type CategoryCounter struct {
+	CategoryID string `json:"category_id"`
+	Counter
+}
+
+type Counters struct {
+	Totals     Counter           `json:"totals"`
+	Feeds      []FeedCounter     `json:"feeds"`
+	Categories []CategoryCounter `json:"categories"`
+}
+
+func (c *Counter) add(other Counter) {
+	c.Unread += other.Unread
+	c.Starred += other.Starred
+	c.Total += other.Total
+}
+
+func feedCounterChange(source string, unread string, starred string, total string) string {
+	return `INSERT INTO feed_counters (feed_id, unread_count, starred_count, total_count)
+		SELECT feed_id, SUM(` + unread + `), SUM(` + starred + `), SUM(` + total + `)
+		FROM ` + source + `
+		GROUP BY feed_id
+		ON CONFLICT (feed_id) DO UPDATE SET
+			unread_count = feed_counters.unread_count + EXCLUDED.unread_count,
+			starred_count = feed_counters.starred_count + EXCLUDED.starred_count,
+			total_count = feed_counters.total_count + EXCLUDED.total_count,
+			updated_at = NOW()`
+}
+
+func recomputeFeedCounters(ctx context.Context, tx dbExecutor) (int64, int64, error) {
+	if _, err := tx.ExecContext(ctx, `LOCK TABLE feed_counters IN EXCLUSIVE MODE`); err != nil {
+		return 0, 0, err
+	}
+	var feeds, corrected int64
+	err := tx.QueryRowContext(ctx, `
+		WITH actual AS (
+			SELECT f.id AS feed_id,
+				COUNT(i.id) FILTER (WHERE NOT i.is_read) AS unread_count,
+				COUNT(i.id) FILTER (WHERE i.is_favorite) AS starred_count,
+				COUNT(i.id) AS total_count
+			FROM feeds f
+			LEFT JOIN items i ON i.feed_id = f.id
+			GROUP BY f.id
+		), fixed AS (
+			INSERT INTO feed_counters (feed_id, unread_count, starred_count, total_count)
+			SELECT a.feed_id, a.unread_count, a.starred_count, a.total_count
+			FROM actual a
+			LEFT JOIN feed_counters fc ON fc.feed_id = a.feed_id
+			WHERE (COALESCE(fc.unread_count, 0), COALESCE(fc.starred_count, 0), COALESCE(fc.total_count, 0))
+				IS DISTINCT FROM (a.unread_count, a.starred_count, a.total_count)
+			ON CONFLICT (feed_id) DO UPDATE SET
+				unread_count = EXCLUDED.unread_count,
+				starred_count = EXCLUDED.starred_count,
+				total_count = EXCLUDED.total_count,
+				updated_at = NOW()
+			RETURNING feed_id
+		)
+		SELECT (SELECT COUNT(*) FROM actual), (SELECT COUNT(*) FROM fixed)
+	`).Scan(&feeds, &corrected)
+	return feeds, corrected, err
+}
+
+func (s *Server) reconcileFeedCounters(ctx context.Context) (int64, int64, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	feeds, corrected, err := recomputeFeedCounters(ctx, tx)
+	if err != nil {
+		return 0, 0, err
+	}
+	return feeds, corrected, tx.Commit()
+}
+
+func (s *Server) runCounterReconcile(ctx context.Context) {
+	_, corrected, err := s.reconcileFeedCounters(ctx)
+	if err != nil {
+		log.Printf("reconcile feed counters: %v", err)
+		return
+	}
+	if corrected > 0 {
+		log.Printf("reconciled counters for %d feeds", corrected)
+	}
+}
+
+func (s *Server) handleRecomputeCounters(c *gin.Context) {
+	feeds, corrected, err := s.reconcileFeedCounters(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, gin.H{"feeds": feeds, "corrected": corrected})
+}
+
+func (s *Server) loadCounters(ctx context.Context) (Counters, error) {
+	counters := Counters{Feeds: make([]FeedCounter, 0), Categories: make([]CategoryCounter, 0)}
+
+	categoryRows, err := s.db.QueryContext(ctx, `SELECT id, parent_id FROM categories ORDER BY name ASC`)
+	if err != nil {
+		return counters, err
+	}
+	defer categoryRows.Close()
+	parents := make(map[int64]int64)
+	categoryIDs := make([]int64, 0)
+	for categoryRows.Next() {
+		var categoryID int64
+		var parentID sql.NullInt64
+		if err := categoryRows.Scan(&categoryID, &parentID); err != nil {
+			return counters, err
+		}
+		if parentID.Valid {
+			parents[categoryID] = parentID.Int64
+		}
+		categoryIDs = append(categoryIDs, categoryID)
+	}
+	if err := categoryRows.Err(); err != nil {
+		return counters, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT f.id, f.category_id,
+			COALESCE(fc.unread_count, 0),
+			COALESCE(fc.starred_count, 0),
+			COALESCE(fc.total_count, 0)
+		FROM feeds f
+		LEFT JOIN feed_counters fc ON fc.feed_id = f.id
+		ORDER BY f.name ASC, f.id ASC
+	`)
+	if err != nil {
+		return counters, err
+	}
+	defer rows.Close()
+
+	categoryCounts := make(map[int64]*Counter, len(categoryIDs))
+	for _, categoryID := range categoryIDs {
+		categoryCounts[categoryID] = &Counter{}
+	}
+	for rows.Next() {
+		var feedID int64
+		var categoryID sql.NullInt64
+		var counter Counter
+		if err := rows.Scan(&feedID, &categoryID, &counter.Unread, &counter.Starred, &counter.Total); err != nil {
+			return counters, err
+		}
+		counters.Feeds = append(counters.Feeds, FeedCounter{FeedID: formatID(feedID), Counter: counter})
+		counters.Totals.add(counter)
+
+		visited := make(map[int64]bool)
+		for current, ok := categoryID.Int64, categoryID.Valid; ok && !visited[current]; current, ok = parents[current] {
+			visited[current] = true
+			if categoryCount, exists := categoryCounts[current]; exists {
+				categoryCount.add(counter)
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return counters, err
+	}
+
+	for _, categoryID := range categoryIDs {
+		counters.Categories = append(counters.Categories, CategoryCounter{CategoryID: formatID(categoryID), Counter: *categoryCounts[categoryID]})
+	}
+	return counters, nil
+}
+
+func countersETag(counters Counters) (string, error) {
+	encoded, err := json.Marshal(counters)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(encoded)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`, nil
+}
+
+func etagMatches(header string, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleCounters(c *gin.Context) {
+	counters, err := s.loadCounters(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	etag, err := countersETag(counters)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("ETag", etag)
+	c.Header("Cache-Control", "no-cache")
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	respondSuccess(c, http.StatusOK, counters)
+}
@@ -33,17 +33,24 @@ func migrate(db *sql.DB) error {
 		`CREATE TABLE IF NOT EXISTS categories (
 			id BIGSERIAL PRIMARY KEY,
 			name TEXT NOT NULL UNIQUE,
+			parent_id BIGINT REFERENCES categories(id) ON DELETE SET NULL,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		)`,
 		`CREATE TABLE IF NOT EXISTS feeds (
 			id BIGSERIAL PRIMARY KEY,
 			name TEXT NOT NULL,
 			url TEXT NOT NULL UNIQUE,
+			site_url TEXT,
+			language TEXT,
+			search_config REGCONFIG NOT NULL DEFAULT 'simple',
 			category_id BIGINT REFERENCES categories(id) ON DELETE SET NULL,
 			fetch_interval_minutes INTEGER NOT NULL DEFAULT 60,
 			last_fetched_at TIMESTAMPTZ,
 			last_status TEXT,
 			last_error TEXT,
+			last_warning TEXT,
+			fetch_count INTEGER NOT NULL DEFAULT 0,
+			fetch_error_count INTEGER NOT NULL DEFAULT 0,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		)`,
 		`CREATE TABLE IF NOT EXISTS items (
@@ -52,10 +59,17 @@ func migrate(db *sql.DB) error {
 			title TEXT NOT NULL,
 			link TEXT NOT NULL,
 			summary TEXT,
+			content TEXT,
+			enclosures JSONB NOT NULL DEFAULT '[]',
+			author TEXT,
+			search_vector TSVECTOR,
 			guid TEXT NOT NULL,
 			published_at TIMESTAMPTZ,
 			is_read BOOLEAN NOT NULL DEFAULT FALSE,
 			is_favorite BOOLEAN NOT NULL DEFAULT FALSE,
+			read_at TIMESTAMPTZ,
+			click_count INTEGER NOT NULL DEFAULT 0,
+			last_clicked_at TIMESTAMPTZ,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
 			UNIQUE(feed_id, guid)
 		)`,
@@ -64,6 +78,74 @@ func migrate(db *sql.DB) error {
 			item_id BIGINT NOT NULL UNIQUE REFERENCES items(id) ON DELETE CASCADE,
 			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
 		)`,
+		`CREATE TABLE IF NOT EXISTS saved_searches (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			category_id BIGINT REFERENCES categories(id) ON DELETE CASCADE,
+			feed_id BIGINT REFERENCES feeds(id) ON DELETE CASCADE,
+			tag TEXT,
+			query TEXT NOT NULL DEFAULT '',
+			unread_only BOOLEAN NOT NULL DEFAULT FALSE,
+			favorite_only BOOLEAN NOT NULL DEFAULT FALSE,
+			since TIMESTAMPTZ,
+			until TIMESTAMPTZ,
+			date_field TEXT NOT NULL DEFAULT 'published',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS tags (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT NOT NULL UNIQUE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS item_tags (
+			item_id BIGINT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+			tag_id BIGINT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			PRIMARY KEY (item_id, tag_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS annotations (
+			id BIGSERIAL PRIMARY KEY,
+			item_id BIGINT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+			kind TEXT NOT NULL,
+			quote TEXT,
+			note TEXT,
+			start_offset INTEGER,
+			end_offset INTEGER,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS operations (
+			id BIGSERIAL PRIMARY KEY,
+			kind TEXT NOT NULL,
+			item_count INTEGER NOT NULL DEFAULT 0,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+			expires_at TIMESTAMPTZ NOT NULL,
+			undone_at TIMESTAMPTZ
+		)`,
+		`CREATE TABLE IF NOT EXISTS operation_items (
+			operation_id BIGINT NOT NULL REFERENCES operations(id) ON DELETE CASCADE,
+			item_id BIGINT NOT NULL REFERENCES items(id) ON DELETE CASCADE,
+			was_read BOOLEAN NOT NULL,
+			PRIMARY KEY (operation_id, item_id)
+		)`,
+		`CREATE TABLE IF NOT EXISTS rules (
+			id BIGSERIAL PRIMARY KEY,
+			name TEXT NOT NULL,
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			feed_id BIGINT REFERENCES feeds(id) ON DELETE CASCADE,
+			category_id BIGINT REFERENCES categories(id) ON DELETE CASCADE,
+			match_mode TEXT NOT NULL DEFAULT 'all',
+			conditions JSONB NOT NULL DEFAULT '[]',
+			actions JSONB NOT NULL DEFAULT '[]',
+			created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`CREATE TABLE IF NOT EXISTS feed_counters (
+			feed_id BIGINT PRIMARY KEY REFERENCES feeds(id) ON DELETE CASCADE,
+			unread_count BIGINT NOT NULL DEFAULT 0,
+			starred_count BIGINT NOT NULL DEFAULT 0,
+			total_count BIGINT NOT NULL DEFAULT 0,
+			updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
 		`ALTER SEQUENCE IF EXISTS categories_id_seq AS BIGINT`,
 		`ALTER SEQUENCE IF EXISTS feeds_id_seq AS BIGINT`,
 		`ALTER SEQUENCE IF EXISTS items_id_seq AS BIGINT`,
@@ -77,11 +159,45 @@ func migrate(db *sql.DB) error {
 		`ALTER TABLE read_later ALTER COLUMN item_id TYPE BIGINT`,
 		`ALTER TABLE items ADD COLUMN IF NOT EXISTS is_read BOOLEAN NOT NULL DEFAULT FALSE`,
 		`ALTER TABLE items ADD COLUMN IF NOT EXISTS is_favorite BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE feeds ADD COLUMN IF NOT EXISTS last_warning TEXT`,
+		`ALTER TABLE feeds ADD COLUMN IF NOT EXISTS fetch_count INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE feeds ADD COLUMN IF NOT EXISTS fetch_error_count INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE categories ADD COLUMN IF NOT EXISTS parent_id BIGINT REFERENCES categories(id) ON DELETE SET NULL`,
+		`ALTER TABLE feeds ADD COLUMN IF NOT EXISTS site_url TEXT`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS content TEXT`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS enclosures JSONB NOT NULL DEFAULT '[]'`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS read_at TIMESTAMPTZ`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS click_count INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS last_clicked_at TIMESTAMPTZ`,
+		`ALTER TABLE feeds ADD COLUMN IF NOT EXISTS language TEXT`,
+		`ALTER TABLE feeds ADD COLUMN IF NOT EXISTS search_config REGCONFIG NOT NULL DEFAULT 'simple'`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS search_vector TSVECTOR`,
+		`ALTER TABLE items ADD COLUMN IF NOT EXISTS author TEXT`,
+		`ALTER TABLE saved_searches ADD COLUMN IF NOT EXISTS tag TEXT`,
+		`ALTER TABLE saved_searches ADD COLUMN IF NOT EXISTS date_field TEXT NOT NULL DEFAULT 'published'`,
+		foreignKeyMigration("saved_searches", "category_id", "categories", "CASCADE"),
+		foreignKeyMigration("saved_searches", "feed_id", "feeds", "CASCADE"),
+		foreignKeyMigration("rules", "category_id", "categories", "CASCADE"),
+		`UPDATE items i SET search_vector = ` + itemSearchVector("f.search_config", "i.title", "i.summary") + `
+		FROM feeds f WHERE f.id = i.feed_id AND i.search_vector IS NULL`,
 		`CREATE INDEX IF NOT EXISTS idx_items_published_at ON items(published_at DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_items_keyset ON items(published_at DESC NULLS LAST, created_at DESC, id DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_items_fetched_keyset ON items(created_at DESC, id DESC)`,
 		`CREATE INDEX IF NOT EXISTS idx_items_feed_id ON items(feed_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_categories_parent_id ON categories(parent_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_items_is_read ON items(is_read)`,
 		`CREATE INDEX IF NOT EXISTS idx_items_is_favorite ON items(is_favorite)`,
-		`CREATE INDEX IF NOT EXISTS idx_items_search ON items USING GIN (to_tsvector('simple', title || ' ' || COALESCE(summary, '')))`,
+		`DROP INDEX IF EXISTS idx_items_search`,
+		`CREATE INDEX IF NOT EXISTS idx_items_search_vector ON items USING GIN (search_vector)`,
+		`CREATE INDEX IF NOT EXISTS idx_item_tags_tag_id ON item_tags(tag_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_annotations_item_id ON annotations(item_id)`,
+		`INSERT INTO feed_counters (feed_id, unread_count, starred_count, total_count)
+		SELECT f.id, COUNT(i.id) FILTER (WHERE NOT i.is_read), COUNT(i.id) FILTER (WHERE i.is_favorite), COUNT(i.id)
+		FROM feeds f
+		LEFT JOIN items i ON i.feed_id = f.id
+		WHERE NOT EXISTS (SELECT 1 FROM feed_counters fc WHERE fc.feed_id = f.id)
+		GROUP BY f.id
+		ON CONFLICT (feed_id) DO NOTHING`,
 	}
 
 	for _, statement := range statements {
@@ -91,3 +207,19 @@ func migrate(db *sql.DB) error {
 	}
 	return nil
 }
+
+var foreignKeyActions = map[string]string{"CASCADE": "c", "SET NULL": "n"}
+
+func foreignKeyMigration(table, column, referencedTable, onDelete string) string {
+	constraint := table + "_" + column + "_fkey"
+	return `DO $$
+		BEGIN
+			IF NOT EXISTS (
+				SELECT 1 FROM pg_constraint
+				WHERE conrelid = '` + table + `'::regclass AND conname = '` + constraint + `' AND confdeltype = '` + foreignKeyActions[onDelete] + `'
+			) THEN
+				ALTER TABLE ` + table + ` DROP CONSTRAINT IF EXISTS ` + constraint + `;
+				ALTER TABLE ` + table + ` ADD CONSTRAINT ` + constraint + ` FOREIGN KEY (` + column + `) REFERENCES ` + referencedTable + `(id) ON DELETE ` + onDelete + `;
+			END IF;
+		END $$`
+}
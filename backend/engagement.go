@@ -0,0 +1,114 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeedEngagement struct {
+	FeedID       string     `json:"feed_id"`
+	FeedName     string     `json:"feed_name"`
+	Items        int        `json:"items"`
+	OpenedItems  int        `json:"opened_items"`
+	Clicks       int        `json:"clicks"`
+	OpenRatio    float64    `json:"open_ratio"`
+	LastOpenedAt *time.Time `json:"last_opened_at"`
+}
+
+func isRedirectableLink(link string) bool {
+	parsed, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || parsed.Host == "" {
+		return false
+	}
+	return parsed.Scheme == "http" || parsed.Scheme == "https"
+}
+
+func (s *Server) handleOpenItem(c *gin.Context) {
+	itemID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid item id")
+		return
+	}
+
+	ctx := c.Request.Context()
+	var link string
+	err = s.db.QueryRowContext(ctx, `SELECT link FROM items WHERE id = $1`, itemID).Scan(&link)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !isRedirectableLink(link) {
+		respondErrorMessage(c, http.StatusUnprocessableEntity, "item has no http(s) link")
+		return
+	}
+
+	if _, err := s.db.ExecContext(ctx, `
+		WITH target AS (
+			SELECT id, is_read FROM items WHERE id = $1 FOR UPDATE
+		), changed AS (
+			UPDATE items i
+			SET is_read = TRUE, read_at = COALESCE(i.read_at, NOW()), click_count = i.click_count + 1, last_clicked_at = NOW()
+			FROM target t
+			WHERE i.id = t.id
+			RETURNING i.feed_id, t.is_read AS was_read
+		), counted AS (
+			`+feedCounterChange("changed", "-(NOT was_read)::integer", "0", "0")+`
+		)
+		SELECT COUNT(*) FROM changed
+	`, itemID); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Redirect(http.StatusFound, strings.TrimSpace(link))
+}
+
+func (s *Server) handleFeedEngagement(c *gin.Context) {
+	rows, err := s.db.Query(`
+		SELECT f.id, f.name,
+			COUNT(i.id),
+			COUNT(i.id) FILTER (WHERE i.click_count > 0),
+			COALESCE(SUM(i.click_count), 0),
+			MAX(i.last_clicked_at)
+		FROM feeds f
+		LEFT JOIN items i ON i.feed_id = f.id
+		GROUP BY f.id
+		ORDER BY f.name ASC, f.id ASC
+	`)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	engagement := make([]FeedEngagement, 0)
+	for rows.Next() {
+		var entry FeedEngagement
+		var feedID int64
+		if err := rows.Scan(&feedID, &entry.FeedName, &entry.Items, &entry.OpenedItems, &entry.Clicks, &entry.LastOpenedAt); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		entry.FeedID = formatID(feedID)
+		if entry.Items > 0 {
+			entry.OpenRatio = float64(entry.OpenedItems) / float64(entry.Items)
+		}
+		engagement = append(engagement, entry)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, engagement)
+}
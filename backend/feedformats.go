@@ -0,0 +1,479 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+)
+
+const (
+	atomNamespace  = "http://www.w3.org/2005/Atom"
+	rss10Namespace = "http://purl.org/rss/1.0/"
+)
+
+type RSSParser struct{}
+
+type AtomParser struct{}
+
+type JSONFeedParser struct{}
+
+type RSS struct {
+	Base    string     `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Channel RSSChannel `xml:"channel"`
+	Items   []RSSItem  `xml:"item"`
+}
+
+type RSSChannel struct {
+	Base        string    `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Title       string    `xml:"title"`
+	Links       []RSSLink `xml:"link"`
+	Description string    `xml:"description"`
+	Language    string    `xml:"language"`
+	DCLanguage  string    `xml:"http://purl.org/dc/elements/1.1/ language"`
+	Items       []RSSItem `xml:"item"`
+}
+
+type RSSLink struct {
+	XMLName xml.Name
+	Value   string `xml:",chardata"`
+}
+
+type RSSItem struct {
+	Base        string         `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Title       string         `xml:"title"`
+	Links       []RSSLink      `xml:"link"`
+	Description string         `xml:"description"`
+	Content     string         `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	GUID        string         `xml:"guid"`
+	PubDate     string         `xml:"pubDate"`
+	DCDate      string         `xml:"http://purl.org/dc/elements/1.1/ date"`
+	Author      string         `xml:"author"`
+	DCCreator   string         `xml:"http://purl.org/dc/elements/1.1/ creator"`
+	Enclosures  []RSSEnclosure `xml:"enclosure"`
+}
+
+type RSSEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type AtomFeed struct {
+	Base     string       `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Lang     string       `xml:"http://www.w3.org/XML/1998/namespace lang,attr"`
+	Title    AtomText     `xml:"title"`
+	Subtitle AtomText     `xml:"subtitle"`
+	Links    []AtomLink   `xml:"link"`
+	Authors  []AtomPerson `xml:"author"`
+	Entries  []AtomEntry  `xml:"entry"`
+}
+
+type AtomEntry struct {
+	Base      string       `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Title     string       `xml:"title"`
+	ID        string       `xml:"id"`
+	Summary   AtomText     `xml:"summary"`
+	Content   AtomText     `xml:"content"`
+	Updated   string       `xml:"updated"`
+	Published string       `xml:"published"`
+	Links     []AtomLink   `xml:"link"`
+	Authors   []AtomPerson `xml:"author"`
+}
+
+type AtomPerson struct {
+	Name string `xml:"name"`
+}
+
+type AtomText struct {
+	Base     string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Type     string `xml:"type,attr"`
+	Body     string `xml:",chardata"`
+	InnerXML string `xml:",innerxml"`
+}
+
+type AtomLink struct {
+	Base   string `xml:"http://www.w3.org/XML/1998/namespace base,attr"`
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr"`
+	Type   string `xml:"type,attr"`
+	Length string `xml:"length,attr"`
+}
+
+type JSONFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url"`
+	Description string         `json:"description"`
+	Language    string         `json:"language"`
+	Items       []JSONFeedItem `json:"items"`
+}
+
+type JSONFeedItem struct {
+	ID            string               `json:"id"`
+	Title         string               `json:"title"`
+	URL           string               `json:"url"`
+	ExternalURL   string               `json:"external_url"`
+	Summary       string               `json:"summary"`
+	ContentText   string               `json:"content_text"`
+	ContentHTML   string               `json:"content_html"`
+	DatePublished string               `json:"date_published"`
+	DateModified  string               `json:"date_modified"`
+	Author        *JSONFeedAuthor      `json:"author"`
+	Authors       []JSONFeedAuthor     `json:"authors"`
+	Attachments   []JSONFeedAttachment `json:"attachments"`
+}
+
+type JSONFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type JSONFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes"`
+}
+
+func (t AtomText) Value() string {
+	if strings.EqualFold(t.Type, "xhtml") {
+		return strings.TrimSpace(t.InnerXML)
+	}
+	return strings.TrimSpace(t.Body)
+}
+
+func rssAuthor(value string) string {
+	value = strings.TrimSpace(value)
+	if open := strings.Index(value, "("); open > 0 && strings.HasSuffix(value, ")") && strings.Contains(value[:open], "@") {
+		if name := strings.TrimSpace(value[open+1 : len(value)-1]); name != "" {
+			return name
+		}
+	}
+	return value
+}
+
+func atomAuthor(entryAuthors, feedAuthors []AtomPerson) string {
+	for _, authors := range [][]AtomPerson{entryAuthors, feedAuthors} {
+		names := make([]string, 0, len(authors))
+		for _, author := range authors {
+			if name := strings.TrimSpace(author.Name); name != "" {
+				names = append(names, name)
+			}
+		}
+		if len(names) > 0 {
+			return strings.Join(names, ", ")
+		}
+	}
+	return ""
+}
+
+func jsonFeedAuthor(item JSONFeedItem) string {
+	names := make([]string, 0, len(item.Authors))
+	for _, author := range item.Authors {
+		if name := strings.TrimSpace(author.Name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 && item.Author != nil {
+		return strings.TrimSpace(item.Author.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func firstRSSLink(links []RSSLink) string {
+	for _, link := range links {
+		if link.XMLName.Space == atomNamespace {
+			continue
+		}
+		if value := strings.TrimSpace(link.Value); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			return trimmed
+		}
+	}
+	return ""
+}
+
+func (RSSParser) Name() string {
+	return "rss"
+}
+
+func (RSSParser) Detect(sniff FeedSniff) int {
+	score := 0
+	switch strings.ToLower(sniff.Root.Local) {
+	case "rss":
+		score = 100
+	case "rdf":
+		score = 60
+		if sniff.HasNamespace(rss10Namespace) {
+			score = 100
+		}
+	default:
+		return 0
+	}
+	if sniff.HasContentType("application/rss+xml", "application/rdf+xml") {
+		score += 10
+	}
+	return score
+}
+
+func (RSSParser) Parse(data []byte, feedURL string) (ParsedFeed, error) {
+	rss, warnings, err := decodeXML[RSS](data)
+	if err != nil {
+		return ParsedFeed{}, err
+	}
+
+	channelBase := resolveBase(resolveBase(feedURL, rss.Base), rss.Channel.Base)
+	rssItems := append(rss.Channel.Items, rss.Items...)
+	items := make([]ParsedItem, 0, len(rssItems))
+	for _, item := range rssItems {
+		base := resolveBase(channelBase, item.Base)
+		rawLink := firstRSSLink(item.Links)
+		rawPublished := firstNonEmpty(item.PubDate, item.DCDate)
+		published := parseTime(item.PubDate)
+		if published == nil {
+			published = parseTime(item.DCDate)
+		}
+		summary := strings.TrimSpace(item.Description)
+		guid := strings.TrimSpace(item.GUID)
+		if guid == "" {
+			guid = rawLink
+		}
+
+		enclosures := make([]Enclosure, 0, len(item.Enclosures))
+		for _, enclosure := range item.Enclosures {
+			if strings.TrimSpace(enclosure.URL) == "" {
+				continue
+			}
+			enclosures = append(enclosures, Enclosure{
+				URL:    resolveURL(base, strings.TrimSpace(enclosure.URL)),
+				Type:   strings.TrimSpace(enclosure.Type),
+				Length: parseLength(enclosure.Length),
+			})
+		}
+
+		items = append(items, ParsedItem{
+			Title:        strings.TrimSpace(item.Title),
+			Link:         resolveURL(base, rawLink),
+			Summary:      resolveHTMLURLs(base, summary),
+			Content:      resolveHTMLURLs(base, strings.TrimSpace(item.Content)),
+			Author:       rssAuthor(firstNonEmpty(item.Author, item.DCCreator)),
+			GUID:         guid,
+			Published:    published,
+			Enclosures:   enclosures,
+			RawLink:      rawLink,
+			RawPublished: rawPublished,
+			HasGUID:      strings.TrimSpace(item.GUID) != "",
+		})
+	}
+
+	return ParsedFeed{
+		Title:       strings.TrimSpace(rss.Channel.Title),
+		SiteURL:     resolveURL(channelBase, firstRSSLink(rss.Channel.Links)),
+		Description: strings.TrimSpace(rss.Channel.Description),
+		Language:    firstNonEmpty(rss.Channel.Language, rss.Channel.DCLanguage),
+		Items:       items,
+		Warnings:    warnings,
+	}, nil
+}
+
+func (AtomParser) Name() string {
+	return "atom"
+}
+
+func (AtomParser) Detect(sniff FeedSniff) int {
+	if strings.ToLower(sniff.Root.Local) != "feed" {
+		return 0
+	}
+	score := 60
+	if sniff.HasNamespace(atomNamespace) {
+		score = 100
+	}
+	if sniff.HasContentType("application/atom+xml") {
+		score += 10
+	}
+	return score
+}
+
+func (AtomParser) Parse(data []byte, feedURL string) (ParsedFeed, error) {
+	feed, warnings, err := decodeXML[AtomFeed](data)
+	if err != nil {
+		return ParsedFeed{}, err
+	}
+
+	feedBase := resolveBase(feedURL, feed.Base)
+	siteURL := ""
+	for _, atomLink := range feed.Links {
+		if (atomLink.Rel == "" || atomLink.Rel == "alternate") && strings.TrimSpace(atomLink.Href) != "" {
+			siteURL = resolveURL(resolveBase(feedBase, atomLink.Base), atomLink.Href)
+			break
+		}
+	}
+
+	items := make([]ParsedItem, 0, len(feed.Entries))
+	for _, entry := range feed.Entries {
+		base := resolveBase(feedBase, entry.Base)
+		link := ""
+		rawLink := ""
+		enclosures := make([]Enclosure, 0)
+		for _, atomLink := range entry.Links {
+			href := strings.TrimSpace(atomLink.Href)
+			if href == "" {
+				continue
+			}
+			linkBase := resolveBase(base, atomLink.Base)
+			switch atomLink.Rel {
+			case "", "alternate":
+				if link == "" {
+					rawLink = href
+					link = resolveURL(linkBase, href)
+				}
+			case "enclosure":
+				enclosures = append(enclosures, Enclosure{
+					URL:    resolveURL(linkBase, href),
+					Type:   strings.TrimSpace(atomLink.Type),
+					Length: parseLength(atomLink.Length),
+				})
+			}
+		}
+		if link == "" {
+			rawLink = strings.TrimSpace(entry.ID)
+			link = rawLink
+		}
+
+		summary := resolveHTMLURLs(resolveBase(base, entry.Summary.Base), entry.Summary.Value())
+		if summary == "" {
+			summary = "Empty summary."
+		}
+		content := resolveHTMLURLs(resolveBase(base, entry.Content.Base), entry.Content.Value())
+
+		rawPublished := firstNonEmpty(entry.Published, entry.Updated)
+		published := parseTime(entry.Published)
+		if published == nil {
+			published = parseTime(entry.Updated)
+		}
+
+		guid := strings.TrimSpace(entry.ID)
+		if guid == "" {
+			guid = rawLink
+		}
+
+		items = append(items, ParsedItem{
+			Title:        strings.TrimSpace(entry.Title),
+			Link:         link,
+			Summary:      summary,
+			Content:      content,
+			Author:       atomAuthor(entry.Authors, feed.Authors),
+			GUID:         guid,
+			Published:    published,
+			Enclosures:   enclosures,
+			RawLink:      rawLink,
+			RawPublished: rawPublished,
+			HasGUID:      strings.TrimSpace(entry.ID) != "",
+		})
+	}
+
+	return ParsedFeed{
+		Title:       feed.Title.Value(),
+		SiteURL:     siteURL,
+		Description: feed.Subtitle.Value(),
+		Language:    strings.TrimSpace(feed.Lang),
+		Items:       items,
+		Warnings:    warnings,
+	}, nil
+}
+
+func (JSONFeedParser) Name() string {
+	return "json"
+}
+
+func (JSONFeedParser) Detect(sniff FeedSniff) int {
+	if !sniff.IsJSON {
+		return 0
+	}
+	score := 10
+	if strings.HasPrefix(sniff.JSONVersion, "https://jsonfeed.org/version/") || strings.HasPrefix(sniff.JSONVersion, "http://jsonfeed.org/version/") {
+		score = 100
+	} else if sniff.HasContentType("application/feed+json") {
+		score = 60
+	}
+	return score
+}
+
+func (JSONFeedParser) Parse(data []byte, feedURL string) (ParsedFeed, error) {
+	var feed JSONFeed
+	if err := json.Unmarshal(data, &feed); err != nil {
+		return ParsedFeed{}, err
+	}
+
+	items := make([]ParsedItem, 0, len(feed.Items))
+	for _, item := range feed.Items {
+		rawLink := strings.TrimSpace(item.URL)
+		if rawLink == "" {
+			rawLink = strings.TrimSpace(item.ExternalURL)
+		}
+		link := resolveURL(feedURL, rawLink)
+		contentHTML := resolveHTMLURLs(feedURL, strings.TrimSpace(item.ContentHTML))
+		summary := strings.TrimSpace(item.Summary)
+		if summary == "" {
+			summary = strings.TrimSpace(item.ContentText)
+		}
+		if summary == "" {
+			summary = contentHTML
+		}
+		content := contentHTML
+		if content == "" {
+			content = strings.TrimSpace(item.ContentText)
+		}
+
+		enclosures := make([]Enclosure, 0, len(item.Attachments))
+		for _, attachment := range item.Attachments {
+			if strings.TrimSpace(attachment.URL) == "" {
+				continue
+			}
+			enclosures = append(enclosures, Enclosure{
+				URL:    resolveURL(feedURL, strings.TrimSpace(attachment.URL)),
+				Type:   strings.TrimSpace(attachment.MimeType),
+				Length: attachment.SizeInBytes,
+			})
+		}
+
+		rawPublished := firstNonEmpty(item.DatePublished, item.DateModified)
+		published := parseTime(item.DatePublished)
+		if published == nil {
+			published = parseTime(item.DateModified)
+		}
+
+		guid := strings.TrimSpace(item.ID)
+		if guid == "" {
+			guid = rawLink
+		}
+
+		items = append(items, ParsedItem{
+			Title:        strings.TrimSpace(item.Title),
+			Link:         link,
+			Summary:      summary,
+			Content:      content,
+			Author:       jsonFeedAuthor(item),
+			GUID:         guid,
+			Published:    published,
+			Enclosures:   enclosures,
+			RawLink:      rawLink,
+			RawPublished: rawPublished,
+			HasGUID:      strings.TrimSpace(item.ID) != "",
+		})
+	}
+
+	return ParsedFeed{
+		Title:       strings.TrimSpace(feed.Title),
+		SiteURL:     resolveURL(feedURL, feed.HomePageURL),
+		Description: strings.TrimSpace(feed.Description),
+		Language:    strings.TrimSpace(feed.Language),
+		Items:       items,
+	}, nil
+}
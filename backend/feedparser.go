@@ -5,229 +5,252 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"mime"
+	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"golang.org/x/net/html/charset"
 )
 
-type ParsedItem struct {
-	Title     string
-	Link      string
-	Summary   string
-	GUID      string
-	Published *time.Time
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+var feedAutoClose = func() []string {
+	autoClose := make([]string, 0, len(xml.HTMLAutoClose))
+	for _, name := range xml.HTMLAutoClose {
+		if name != "link" {
+			autoClose = append(autoClose, name)
+		}
+	}
+	return autoClose
+}()
+
+var feedParsers = NewFeedParserRegistry(RSSParser{}, AtomParser{}, JSONFeedParser{})
+
+type FeedParser interface {
+	Name() string
+	Detect(sniff FeedSniff) int
+	Parse(data []byte, feedURL string) (ParsedFeed, error)
 }
 
-type RSS struct {
-	Channel RSSChannel `xml:"channel"`
+type FeedSniff struct {
+	ContentType string
+	IsJSON      bool
+	JSONVersion string
+	Root        xml.Name
+	Namespaces  []string
 }
 
-type RSSChannel struct {
-	Items []RSSItem `xml:"item"`
+type ParsedFeed struct {
+	Format      string
+	Title       string
+	SiteURL     string
+	Description string
+	Language    string
+	Items       []ParsedItem
+	Warnings    []ParseWarning
 }
 
-type RSSItem struct {
-	Title       string `xml:"title"`
-	Link        string `xml:"link"`
-	Description string `xml:"description"`
-	GUID        string `xml:"guid"`
-	PubDate     string `xml:"pubDate"`
+type ParseWarning struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
 }
 
-type AtomFeed struct {
-	Entries []AtomEntry `xml:"entry"`
+type ParsedItem struct {
+	Title        string
+	Link         string
+	Summary      string
+	Content      string
+	Author       string
+	GUID         string
+	Published    *time.Time
+	Enclosures   []Enclosure
+	RawLink      string
+	RawPublished string
+	HasGUID      bool
 }
 
-type AtomEntry struct {
-	Title     string     `xml:"title"`
-	ID        string     `xml:"id"`
-	Summary   string     `xml:"summary"`
-	Content   string     `xml:"content"`
-	Updated   string     `xml:"updated"`
-	Published string     `xml:"published"`
-	Links     []AtomLink `xml:"link"`
+type Enclosure struct {
+	URL    string `json:"url"`
+	Type   string `json:"type,omitempty"`
+	Length int64  `json:"length,omitempty"`
 }
 
-type AtomLink struct {
-	Href string `xml:"href,attr"`
-	Rel  string `xml:"rel,attr"`
+type FeedParserRegistry struct {
+	parsers []FeedParser
 }
 
-type JSONFeed struct {
-	Items []JSONFeedItem `json:"items"`
+func NewFeedParserRegistry(parsers ...FeedParser) *FeedParserRegistry {
+	registry := &FeedParserRegistry{}
+	for _, parser := range parsers {
+		registry.Register(parser)
+	}
+	return registry
 }
 
-type JSONFeedItem struct {
-	ID            string `json:"id"`
-	Title         string `json:"title"`
-	URL           string `json:"url"`
-	ExternalURL   string `json:"external_url"`
-	Summary       string `json:"summary"`
-	ContentText   string `json:"content_text"`
-	ContentHTML   string `json:"content_html"`
-	DatePublished string `json:"date_published"`
-	DateModified  string `json:"date_modified"`
+func (r *FeedParserRegistry) Register(parser FeedParser) {
+	r.parsers = append(r.parsers, parser)
 }
 
-func parseFeed(data []byte) ([]ParsedItem, error) {
-	trimmed := bytes.TrimSpace(data)
+func (r *FeedParserRegistry) Parse(data []byte, contentType, feedURL string) (ParsedFeed, error) {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(data, utf8BOM))
 	if len(trimmed) == 0 {
-		return nil, fmt.Errorf("empty feed")
+		return ParsedFeed{}, fmt.Errorf("empty feed")
 	}
 
-	if trimmed[0] == '{' || trimmed[0] == '[' {
-		return parseJSONFeed(trimmed)
+	sniff, err := sniffFeed(trimmed, contentType)
+	if err != nil {
+		return ParsedFeed{}, err
 	}
 
-	decoder := xml.NewDecoder(bytes.NewReader(trimmed))
-	for {
-		token, err := decoder.Token()
-		if err != nil {
-			return nil, fmt.Errorf("read xml: %w", err)
+	var selected FeedParser
+	bestScore := 0
+	for _, parser := range r.parsers {
+		if score := parser.Detect(sniff); score > bestScore {
+			selected = parser
+			bestScore = score
 		}
-		switch element := token.(type) {
-		case xml.StartElement:
-			switch strings.ToLower(element.Name.Local) {
-			case "rss", "rdf", "rdf:rdf":
-				return parseRSSFeed(trimmed)
-			case "feed":
-				return parseAtomFeed(trimmed)
-			default:
-				return nil, fmt.Errorf("unsupported feed root: %s", element.Name.Local)
-			}
+	}
+	if selected == nil {
+		if sniff.IsJSON {
+			return ParsedFeed{}, fmt.Errorf("unsupported json feed")
 		}
+		return ParsedFeed{}, fmt.Errorf("unsupported feed root: %s", sniff.Root.Local)
+	}
+
+	feed, err := selected.Parse(trimmed, feedURL)
+	if err != nil {
+		return ParsedFeed{}, err
 	}
+	feed.Format = selected.Name()
+	return feed, nil
+}
+
+func parseFeed(data []byte, contentType, feedURL string) (ParsedFeed, error) {
+	return feedParsers.Parse(data, contentType, feedURL)
 }
 
-func parseRSSFeed(data []byte) ([]ParsedItem, error) {
-	var rss RSS
-	if err := xml.Unmarshal(data, &rss); err != nil {
-		return nil, err
+func sniffFeed(data []byte, contentType string) (FeedSniff, error) {
+	sniff := FeedSniff{}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		sniff.ContentType = strings.ToLower(mediaType)
 	}
 
-	items := make([]ParsedItem, 0, len(rss.Channel.Items))
-	for _, item := range rss.Channel.Items {
-		published := parseTime(item.PubDate)
-		summary := strings.TrimSpace(item.Description)
-		guid := strings.TrimSpace(item.GUID)
-		if guid == "" {
-			guid = strings.TrimSpace(item.Link)
+	if data[0] == '{' || data[0] == '[' {
+		sniff.IsJSON = true
+		var header struct {
+			Version string `json:"version"`
 		}
-		items = append(items, ParsedItem{
-			Title:     strings.TrimSpace(item.Title),
-			Link:      strings.TrimSpace(item.Link),
-			Summary:   summary,
-			GUID:      guid,
-			Published: published,
-		})
-	}
-	return items, nil
-}
-
-func parseAtomFeed(data []byte) ([]ParsedItem, error) {
-	var feed AtomFeed
-	if err := xml.Unmarshal(data, &feed); err != nil {
-		return nil, err
-	}
-
-	items := make([]ParsedItem, 0, len(feed.Entries))
-	for _, entry := range feed.Entries {
-		link := ""
-		for _, atomLink := range entry.Links {
-			if atomLink.Rel == "" || atomLink.Rel == "alternate" {
-				link = atomLink.Href
-				break
-			}
-		}
-		if link == "" {
-			link = entry.ID
+		if err := json.Unmarshal(data, &header); err == nil {
+			sniff.JSONVersion = strings.TrimSpace(header.Version)
 		}
+		return sniff, nil
+	}
 
-		summary := strings.TrimSpace(entry.Summary)
-		if summary == "" {
-			summary = "Empty summary."
+	decoder := newXMLDecoder(sanitizeXML(data), false)
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return sniff, fmt.Errorf("read xml: %w", err)
 		}
+		element, ok := token.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		sniff.Root = element.Name
+		for _, attr := range element.Attr {
+			if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+				sniff.Namespaces = append(sniff.Namespaces, strings.TrimSpace(attr.Value))
+			}
+		}
+		return sniff, nil
+	}
+}
 
-		published := parseTime(entry.Published)
-		if published == nil {
-			published = parseTime(entry.Updated)
+func (s FeedSniff) HasNamespace(namespace string) bool {
+	if s.Root.Space == namespace {
+		return true
+	}
+	for _, declared := range s.Namespaces {
+		if declared == namespace {
+			return true
 		}
+	}
+	return false
+}
 
-		guid := strings.TrimSpace(entry.ID)
-		if guid == "" {
-			guid = strings.TrimSpace(link)
+func (s FeedSniff) HasContentType(contentTypes ...string) bool {
+	for _, contentType := range contentTypes {
+		if s.ContentType == contentType {
+			return true
 		}
+	}
+	return false
+}
+
+func decodeXML[T any](data []byte) (T, []ParseWarning, error) {
+	var strict T
+	strictErr := newXMLDecoder(data, true).Decode(&strict)
+	if strictErr == nil {
+		return strict, nil, nil
+	}
 
-		items = append(items, ParsedItem{
-			Title:     strings.TrimSpace(entry.Title),
-			Link:      strings.TrimSpace(link),
-			Summary:   summary,
-			GUID:      guid,
-			Published: published,
-		})
+	var lenient T
+	if err := newXMLDecoder(sanitizeXML(data), false).Decode(&lenient); err != nil {
+		return lenient, nil, strictErr
 	}
-	return items, nil
+	return lenient, []ParseWarning{{
+		Code:    "lenient_xml",
+		Message: fmt.Sprintf("parsed in lenient mode: %v", strictErr),
+	}}, nil
 }
 
-func parseJSONFeed(data []byte) ([]ParsedItem, error) {
-	var feed JSONFeed
-	if err := json.Unmarshal(data, &feed); err != nil {
-		return nil, err
+func newXMLDecoder(data []byte, strict bool) *xml.Decoder {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	decoder.CharsetReader = charset.NewReaderLabel
+	if !strict {
+		decoder.Strict = false
+		decoder.AutoClose = feedAutoClose
+		decoder.Entity = xml.HTMLEntity
 	}
+	return decoder
+}
 
-	items := make([]ParsedItem, 0, len(feed.Items))
-	for _, item := range feed.Items {
-		link := strings.TrimSpace(item.URL)
-		if link == "" {
-			link = strings.TrimSpace(item.ExternalURL)
-		}
-		summary := strings.TrimSpace(item.Summary)
-		if summary == "" {
-			summary = strings.TrimSpace(item.ContentText)
-		}
-		if summary == "" {
-			summary = strings.TrimSpace(item.ContentHTML)
+func sanitizeXML(data []byte) []byte {
+	data = bytes.TrimPrefix(data, utf8BOM)
+	sanitized := make([]byte, 0, len(data))
+	for len(data) > 0 {
+		r, size := utf8.DecodeRune(data)
+		if r == utf8.RuneError && size == 1 {
+			sanitized = append(sanitized, data[0])
+		} else if isValidXMLChar(r) {
+			sanitized = append(sanitized, data[:size]...)
 		}
+		data = data[size:]
+	}
+	return sanitized
+}
 
-		published := parseTime(item.DatePublished)
-		if published == nil {
-			published = parseTime(item.DateModified)
-		}
+func isValidXMLChar(r rune) bool {
+	return r == '\t' || r == '\n' || r == '\r' ||
+		(r >= 0x20 && r <= 0xD7FF) ||
+		(r >= 0xE000 && r <= 0xFFFD) ||
+		(r >= 0x10000 && r <= 0x10FFFF)
+}
 
-		guid := strings.TrimSpace(item.ID)
-		if guid == "" {
-			guid = link
-		}
+func parseLength(value string) int64 {
+	parsed, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || parsed < 0 {
+		return 0
+	}
+	return parsed
+}
 
-		items = append(items, ParsedItem{
-			Title:     strings.TrimSpace(item.Title),
-			Link:      link,
-			Summary:   summary,
-			GUID:      guid,
-			Published: published,
-		})
-	}
-	return items, nil
-}
-
-func parseTime(value string) *time.Time {
-	value = strings.TrimSpace(value)
-	if value == "" {
-		return nil
-	}
-	layouts := []string{
-		time.RFC3339,
-		time.RFC3339Nano,
-		time.RFC1123Z,
-		time.RFC1123,
-		time.RFC822Z,
-		time.RFC822,
-		time.RFC850,
-		"Mon, 2 Jan 2006 15:04:05 -0700",
-	}
-	for _, layout := range layouts {
-		if parsed, err := time.Parse(layout, value); err == nil {
-			return &parsed
-		}
+func warningMessages(warnings []ParseWarning) string {
+	messages := make([]string, 0, len(warnings))
+	for _, warning := range warnings {
+		messages = append(messages, warning.Message)
 	}
-	return nil
+	return strings.Join(messages, "; ")
 }
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const malformedRSS = "\xEF\xBB\xBF<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n" +
+	"<rss version=\"2.0\"><channel><title>News&nbsp;Desk</title>\n" +
+	"<item><title>Fish & Chips\x01\x0B</title><link>https://example.com/a</link>" +
+	"<guid>a</guid><pubDate>Mon, 02 Jun 2025 10:00:00 GMT</pubDate></item>\n" +
+	"</channel></rss>"
+
+func TestSanitizeXML(t *testing.T) {
+	input := []byte("\xEF\xBB\xBF<a>x\x00y\x01z\x1F\ttab\ré\xF0\x9F\x98\x80</a>")
+	got := sanitizeXML(input)
+	want := []byte("<a>xyz\ttab\ré\xF0\x9F\x98\x80</a>")
+	if !bytes.Equal(got, want) {
+		t.Fatalf("sanitizeXML() = %q, want %q", got, want)
+	}
+}
+
+func TestDecodeXMLStrictHasNoWarnings(t *testing.T) {
+	rss, warnings, err := decodeXML[RSS]([]byte(`<rss><channel><title>Clean &amp; simple</title></channel></rss>`))
+	if err != nil {
+		t.Fatalf("decodeXML() error = %v", err)
+	}
+	if len(warnings) != 0 {
+		t.Fatalf("decodeXML() warnings = %v, want none", warnings)
+	}
+	if rss.Channel.Title != "Clean & simple" {
+		t.Fatalf("title = %q", rss.Channel.Title)
+	}
+}
+
+func TestDecodeXMLLenientFallback(t *testing.T) {
+	rss, warnings, err := decodeXML[RSS]([]byte(malformedRSS))
+	if err != nil {
+		t.Fatalf("decodeXML() error = %v", err)
+	}
+	if len(warnings) != 1 || warnings[0].Code != "lenient_xml" {
+		t.Fatalf("decodeXML() warnings = %v, want one lenient_xml warning", warnings)
+	}
+	if !strings.Contains(warnings[0].Message, "lenient") {
+		t.Fatalf("warning message = %q", warnings[0].Message)
+	}
+	if rss.Channel.Title != "News\u00a0Desk" {
+		t.Fatalf("channel title = %q", rss.Channel.Title)
+	}
+	if len(rss.Channel.Items) != 1 || rss.Channel.Items[0].Title != "Fish & Chips" {
+		t.Fatalf("items = %+v", rss.Channel.Items)
+	}
+}
+
+func TestParseFeedRecordsLenientWarning(t *testing.T) {
+	feed, err := parseFeed([]byte(malformedRSS), "application/rss+xml", "https://example.com/feed")
+	if err != nil {
+		t.Fatalf("parseFeed() error = %v", err)
+	}
+	if len(feed.Warnings) == 0 {
+		t.Fatal("parseFeed() recorded no warnings for malformed XML")
+	}
+	if message := warningMessages(feed.Warnings); !strings.Contains(message, "lenient") {
+		t.Fatalf("warningMessages() = %q", message)
+	}
+	if len(feed.Items) != 1 || feed.Items[0].Title != "Fish & Chips" {
+		t.Fatalf("items = %+v", feed.Items)
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -14,8 +16,12 @@ import (
 func (s *Server) startFetcher(ctx context.Context) {
 	ticker := time.NewTicker(time.Duration(s.config.FetchIntervalMinutes) * time.Minute)
 	defer ticker.Stop()
+	reconcileTicker := time.NewTicker(time.Duration(s.config.CounterReconcileHours) * time.Hour)
+	defer reconcileTicker.Stop()
 
 	s.fetchDueFeeds(ctx)
+	s.pruneItems(ctx)
+	s.cleanupOperations(ctx)
 
 	for {
 		select {
@@ -23,10 +29,42 @@ func (s *Server) startFetcher(ctx context.Context) {
 			return
 		case <-ticker.C:
 			s.fetchDueFeeds(ctx)
+			s.pruneItems(ctx)
+			s.cleanupOperations(ctx)
+		case <-reconcileTicker.C:
+			s.runCounterReconcile(ctx)
 		}
 	}
 }
 
+func (s *Server) pruneItems(ctx context.Context) {
+	if s.config.RetentionDays <= 0 {
+		return
+	}
+	var count int64
+	err := s.db.QueryRowContext(ctx, `
+		WITH removed AS (
+			DELETE FROM items i
+			WHERE i.created_at < NOW() - make_interval(days => $1)
+			  AND i.is_favorite = FALSE
+			  AND NOT EXISTS (SELECT 1 FROM read_later rl WHERE rl.item_id = i.id)
+			  AND NOT EXISTS (SELECT 1 FROM item_tags it WHERE it.item_id = i.id)
+			  AND NOT EXISTS (SELECT 1 FROM annotations a WHERE a.item_id = i.id)
+			RETURNING i.feed_id, i.is_read, i.is_favorite
+		), counted AS (
+			`+feedCounterChange("removed", "-(NOT is_read)::integer", "-is_favorite::integer", "-1")+`
+		)
+		SELECT COUNT(*) FROM removed
+	`, s.config.RetentionDays).Scan(&count)
+	if err != nil {
+		log.Printf("prune items: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("pruned %d items older than %d days", count, s.config.RetentionDays)
+	}
+}
+
 func (s *Server) fetchDueFeeds(ctx context.Context) {
 	rows, err := s.db.QueryContext(ctx, `
 		SELECT id
@@ -84,42 +122,77 @@ func (s *Server) fetchAllFeeds(ctx context.Context) error {
 
 func (s *Server) fetchFeedByID(ctx context.Context, id int64) error {
 	var feedURL string
-	query := `SELECT url FROM feeds WHERE id = $1`
-	if err := s.db.QueryRowContext(ctx, query, id).Scan(&feedURL); err != nil {
+	var language sql.NullString
+	query := `SELECT url, language FROM feeds WHERE id = $1`
+	if err := s.db.QueryRowContext(ctx, query, id).Scan(&feedURL, &language); err != nil {
 		return err
 	}
 
-	request, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
+	document, err := downloadFeed(ctx, feedURL)
 	if err != nil {
 		return s.updateFeedStatus(ctx, id, "error", err)
 	}
 
-	client := &http.Client{Timeout: 15 * time.Second}
-	response, err := client.Do(request)
+	feed, err := parseFeed(document.Body, document.ContentType, document.FinalURL)
 	if err != nil {
 		return s.updateFeedStatus(ctx, id, "error", err)
 	}
-	defer response.Body.Close()
 
-	if response.StatusCode < 200 || response.StatusCode >= 300 {
-		return s.updateFeedStatus(ctx, id, "error", fmt.Errorf("feed status: %s", response.Status))
+	if !language.Valid && feed.Language != "" {
+		if err := s.setFeedLanguage(ctx, id, feed.Language); err != nil {
+			return s.updateFeedStatus(ctx, id, "error", err)
+		}
 	}
 
-	body, err := io.ReadAll(response.Body)
-	if err != nil {
+	if err := s.storeItems(ctx, id, feed.Items); err != nil {
 		return s.updateFeedStatus(ctx, id, "error", err)
 	}
 
-	items, err := parseFeed(body)
+	if feed.SiteURL != "" {
+		if _, err := s.db.ExecContext(ctx, `UPDATE feeds SET site_url = $2 WHERE id = $1`, id, feed.SiteURL); err != nil {
+			return s.updateFeedStatus(ctx, id, "error", err)
+		}
+	}
+
+	if len(feed.Warnings) > 0 {
+		return s.updateFeedWarning(ctx, id, warningMessages(feed.Warnings))
+	}
+	return s.updateFeedStatus(ctx, id, "success", nil)
+}
+
+type feedDocument struct {
+	Body        []byte
+	ContentType string
+	FinalURL    string
+}
+
+func downloadFeed(ctx context.Context, feedURL string) (feedDocument, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, feedURL, nil)
 	if err != nil {
-		return s.updateFeedStatus(ctx, id, "error", err)
+		return feedDocument{}, err
 	}
 
-	if err := s.storeItems(ctx, id, items); err != nil {
-		return s.updateFeedStatus(ctx, id, "error", err)
+	client := &http.Client{Timeout: 15 * time.Second}
+	response, err := client.Do(request)
+	if err != nil {
+		return feedDocument{}, err
 	}
+	defer response.Body.Close()
 
-	return s.updateFeedStatus(ctx, id, "success", nil)
+	if response.StatusCode < 200 || response.StatusCode >= 300 {
+		return feedDocument{}, fmt.Errorf("feed status: %s", response.Status)
+	}
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return feedDocument{}, err
+	}
+
+	return feedDocument{
+		Body:        body,
+		ContentType: response.Header.Get("Content-Type"),
+		FinalURL:    response.Request.URL.String(),
+	}, nil
 }
 
 func (s *Server) storeItems(ctx context.Context, feedID int64, items []ParsedItem) error {
@@ -127,16 +200,38 @@ func (s *Server) storeItems(ctx context.Context, feedID int64, items []ParsedIte
 		return nil
 	}
 
-	stmt, err := s.db.PrepareContext(ctx, `
-		INSERT INTO items (feed_id, title, link, summary, guid, published_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-		ON CONFLICT (feed_id, guid) DO NOTHING
+	rules, subject, err := s.loadIngestRules(ctx, feedID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		WITH inserted AS (
+			INSERT INTO items (feed_id, title, link, summary, content, enclosures, guid, published_at, author, is_read, is_favorite, search_vector)
+			SELECT $1::bigint, $2::text, $3::text, $4::text, $5::text, $6::jsonb, $7::text, $8::timestamptz, $9::text, $10::boolean, $11::boolean, `+itemSearchVector("f.search_config", "$2::text", "$4::text")+`
+			FROM feeds f
+			WHERE f.id = $1
+			ON CONFLICT (feed_id, guid) DO NOTHING
+			RETURNING id, feed_id, is_read, is_favorite
+		), counted AS (
+			`+feedCounterChange("inserted", "(NOT is_read)::integer", "is_favorite::integer", "1")+`
+		)
+		SELECT id FROM inserted
 	`)
 	if err != nil {
 		return err
 	}
 	defer stmt.Close()
 
+	fetchedAt := time.Now()
 	for _, item := range items {
 		guid := strings.TrimSpace(item.GUID)
 		if guid == "" {
@@ -147,11 +242,45 @@ func (s *Server) storeItems(ctx context.Context, feedID int64, items []ParsedIte
 		}
 
 		summary := strings.TrimSpace(item.Summary)
-		if _, err := stmt.ExecContext(ctx, feedID, item.Title, item.Link, summary, guid, item.Published); err != nil {
+		subject.Title = item.Title
+		subject.Summary = summary
+		subject.Link = item.Link
+		subject.Author = item.Author
+		outcome := evaluateRules(rules, subject)
+		if outcome.Drop {
+			continue
+		}
+
+		published := clampPublished(item.Published, fetchedAt)
+		enclosures, err := marshalEnclosures(item.Enclosures)
+		if err != nil {
+			return err
+		}
+		author := sql.NullString{String: item.Author, Valid: item.Author != ""}
+		var itemID int64
+		err = stmt.QueryRowContext(ctx, feedID, item.Title, item.Link, summary, strings.TrimSpace(item.Content), enclosures, guid, published, author, outcome.Read, outcome.Favorite).Scan(&itemID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if err := applyRuleOutcome(ctx, tx, itemID, outcome); err != nil {
 			return err
 		}
 	}
-	return nil
+	return tx.Commit()
+}
+
+func marshalEnclosures(enclosures []Enclosure) (string, error) {
+	if len(enclosures) == 0 {
+		return "[]", nil
+	}
+	encoded, err := json.Marshal(enclosures)
+	if err != nil {
+		return "", err
+	}
+	return string(encoded), nil
 }
 
 func (s *Server) updateFeedStatus(ctx context.Context, id int64, status string, fetchErr error) error {
@@ -161,8 +290,19 @@ func (s *Server) updateFeedStatus(ctx context.Context, id int64, status string,
 	}
 	_, err := s.db.ExecContext(ctx, `
 		UPDATE feeds
-		SET last_fetched_at = NOW(), last_status = $2, last_error = $3
+		SET last_fetched_at = NOW(), last_status = $2, last_error = $3, last_warning = NULL,
+			fetch_count = fetch_count + 1,
+			fetch_error_count = fetch_error_count + CASE WHEN $2 = 'error' THEN 1 ELSE 0 END
 		WHERE id = $1
 	`, id, status, errMessage)
 	return err
 }
+
+func (s *Server) updateFeedWarning(ctx context.Context, id int64, warning string) error {
+	_, err := s.db.ExecContext(ctx, `
+		UPDATE feeds
+		SET last_fetched_at = NOW(), last_status = 'warning', last_error = NULL, last_warning = $2, fetch_count = fetch_count + 1
+		WHERE id = $1
+	`, id, warning)
+	return err
+}
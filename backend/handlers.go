@@ -2,6 +2,8 @@ package main
 
 import (
 	"database/sql"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
@@ -20,6 +22,7 @@ type Server struct {
 type Category struct {
 	ID        string    `json:"id"`
 	Name      string    `json:"name"`
+	ParentID  *string   `json:"parent_id"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
@@ -27,11 +30,14 @@ type Feed struct {
 	ID            string     `json:"id"`
 	Name          string     `json:"name"`
 	URL           string     `json:"url"`
+	SiteURL       *string    `json:"site_url"`
+	Language      *string    `json:"language"`
 	CategoryID    *string    `json:"category_id"`
 	FetchInterval int        `json:"fetch_interval_minutes"`
 	LastFetchedAt *time.Time `json:"last_fetched_at"`
 	LastStatus    *string    `json:"last_status"`
 	LastError     *string    `json:"last_error"`
+	LastWarning   *string    `json:"last_warning"`
 	CategoryName  *string    `json:"category_name"`
 }
 
@@ -41,16 +47,20 @@ type TransferPayload struct {
 }
 
 type TransferCategory struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID         string  `json:"id"`
+	Name       string  `json:"name"`
+	ParentID   *string `json:"parent_id,omitempty"`
+	ParentName *string `json:"parent_name,omitempty"`
 }
 
 type TransferFeed struct {
-	ID           string  `json:"id"`
-	Name         string  `json:"name"`
-	URL          string  `json:"url"`
-	CategoryID   *string `json:"category_id"`
-	CategoryName *string `json:"category_name"`
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	URL           string  `json:"url"`
+	SiteURL       *string `json:"site_url,omitempty"`
+	CategoryID    *string `json:"category_id"`
+	CategoryName  *string `json:"category_name"`
+	FetchInterval *int    `json:"fetch_interval_minutes,omitempty"`
 }
 
 type Item struct {
@@ -65,6 +75,9 @@ type Item struct {
 	PublishedAt *time.Time `json:"published_at"`
 	IsRead      bool       `json:"is_read"`
 	IsFavorite  bool       `json:"is_favorite"`
+	Tags        []string   `json:"tags"`
+	Rank        *float64   `json:"rank,omitempty"`
+	Snippet     *string    `json:"snippet,omitempty"`
 }
 
 type ReadLaterEntry struct {
@@ -82,7 +95,8 @@ type ReadLaterEntry struct {
 }
 
 type createCategoryRequest struct {
-	Name string `json:"name"`
+	Name     string  `json:"name"`
+	ParentID *string `json:"parent_id"`
 }
 
 type createFeedRequest struct {
@@ -104,6 +118,13 @@ type batchReadRequest struct {
 	Read    bool     `json:"read"`
 }
 
+type markReadRequest struct {
+	Scope     string  `json:"scope"`
+	ID        string  `json:"id"`
+	OlderThan *string `json:"older_than"`
+	BeforeID  *string `json:"before_id"`
+}
+
 type updateItemFavoriteRequest struct {
 	Favorite bool `json:"favorite"`
 }
@@ -111,37 +132,81 @@ type updateItemFavoriteRequest struct {
 type updateFeedRequest struct {
 	Name       *string `json:"name"`
 	CategoryID *string `json:"category_id"`
+	Language   *string `json:"language"`
 }
 
 type ItemsResponse struct {
-	Items    []Item `json:"items"`
-	Total    int    `json:"total"`
-	Page     int    `json:"page"`
-	PageSize int    `json:"page_size"`
+	Items      []Item  `json:"items"`
+	Total      *int    `json:"total"`
+	Page       int     `json:"page,omitempty"`
+	PageSize   int     `json:"page_size"`
+	NextCursor *string `json:"next_cursor"`
+	PrevCursor *string `json:"prev_cursor"`
 }
 
 func (s *Server) routes() http.Handler {
 	router := gin.New()
-	router.Use(gin.Logger(), gin.Recovery(), corsMiddleware(), responseMiddleware())
+	router.Use(gin.Logger(), gin.CustomRecovery(recoverPanic), corsMiddleware(), responseMiddleware())
 
 	api := router.Group("/api")
 	api.GET("/health", s.handleHealth)
 	api.GET("/categories", s.handleListCategories)
 	api.POST("/categories", s.handleCreateCategory)
+	api.PATCH("/categories/:id", s.handleUpdateCategory)
 	api.DELETE("/categories/:id", s.handleDeleteCategory)
+	api.POST("/categories/:id/merge", s.handleMergeCategory)
 	api.GET("/feeds", s.handleListFeeds)
 	api.POST("/feeds", s.handleCreateFeed)
+	api.POST("/feeds/preview", s.handlePreviewFeed)
 	api.PATCH("/feeds/:id", s.handleUpdateFeed)
 	api.DELETE("/feeds/:id", s.handleDeleteFeed)
 	api.POST("/feeds/:id/refresh", s.handleRefreshFeed)
+	api.GET("/feeds/:id/stats", s.handleFeedStats)
 	api.GET("/items", s.handleListItems)
 	api.GET("/items/unread-count", s.handleUnreadCount)
+	api.GET("/counters", s.handleCounters)
+	api.POST("/admin/counters/recompute", s.handleRecomputeCounters)
+	api.GET("/items/:id", s.handleGetItem)
+	api.GET("/items/:id/open", s.handleOpenItem)
 	api.PATCH("/items/:id/read", s.handleUpdateItemRead)
 	api.POST("/items/read-batch", s.handleBatchRead)
+	api.POST("/items/mark-read", s.handleMarkRead)
 	api.PATCH("/items/:id/favorite", s.handleUpdateItemFavorite)
+	api.GET("/items/:id/tags", s.handleListItemTags)
+	api.POST("/items/:id/tags", s.handleAttachItemTags)
+	api.DELETE("/items/:id/tags/:tagID", s.handleDetachItemTag)
+	api.POST("/items/tag-batch", s.handleBatchTag)
+	api.GET("/items/:id/annotations", s.handleListAnnotations)
+	api.POST("/items/:id/annotations", s.handleCreateAnnotation)
+	api.PATCH("/items/:id/annotations/:annotationID", s.handleUpdateAnnotation)
+	api.DELETE("/items/:id/annotations/:annotationID", s.handleDeleteAnnotation)
+	api.GET("/annotations/export", s.handleExportAnnotations)
+	api.GET("/tags", s.handleListTags)
+	api.POST("/tags", s.handleCreateTag)
+	api.PATCH("/tags/:id", s.handleRenameTag)
+	api.POST("/tags/:id/merge", s.handleMergeTag)
+	api.DELETE("/tags/:id", s.handleDeleteTag)
 	api.POST("/refresh", s.handleRefreshAll)
 	api.GET("/export", s.handleExportData)
+	api.GET("/export.opml", s.handleExportOPML)
 	api.POST("/import", s.handleImportData)
+	api.GET("/backup", s.handleBackup)
+	api.POST("/restore", s.handleRestore)
+	api.GET("/saved-searches", s.handleListSavedSearches)
+	api.POST("/saved-searches", s.handleCreateSavedSearch)
+	api.GET("/saved-searches/:id", s.handleGetSavedSearch)
+	api.PATCH("/saved-searches/:id", s.handleUpdateSavedSearch)
+	api.DELETE("/saved-searches/:id", s.handleDeleteSavedSearch)
+	api.GET("/rules", s.handleListRules)
+	api.POST("/rules", s.handleCreateRule)
+	api.POST("/rules/test", s.handleTestRule)
+	api.GET("/rules/:id", s.handleGetRule)
+	api.PATCH("/rules/:id", s.handleUpdateRule)
+	api.DELETE("/rules/:id", s.handleDeleteRule)
+	api.POST("/rules/:id/apply", s.handleApplyRule)
+	api.POST("/operations/:id/undo", s.handleUndoOperation)
+	api.GET("/stats/engagement", s.handleFeedEngagement)
+	api.GET("/stats/feeds", s.handleListFeedStats)
 	api.GET("/read-later", s.handleListReadLater)
 	api.POST("/read-later", s.handleCreateReadLater)
 	api.DELETE("/read-later/:itemID", s.handleDeleteReadLater)
@@ -154,7 +219,7 @@ func (s *Server) handleHealth(c *gin.Context) {
 }
 
 func (s *Server) handleListCategories(c *gin.Context) {
-	rows, err := s.db.Query(`SELECT id, name, created_at FROM categories ORDER BY name ASC`)
+	rows, err := s.db.Query(`SELECT id, name, parent_id, created_at FROM categories ORDER BY name ASC`)
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, err)
 		return
@@ -165,11 +230,13 @@ func (s *Server) handleListCategories(c *gin.Context) {
 	for rows.Next() {
 		var category Category
 		var categoryID int64
-		if err := rows.Scan(&categoryID, &category.Name, &category.CreatedAt); err != nil {
+		var parentID sql.NullInt64
+		if err := rows.Scan(&categoryID, &category.Name, &parentID, &category.CreatedAt); err != nil {
 			respondError(c, http.StatusInternalServerError, err)
 			return
 		}
 		category.ID = formatID(categoryID)
+		category.ParentID = formatNullableID(parentID)
 		categories = append(categories, category)
 	}
 	respondSuccess(c, http.StatusOK, categories)
@@ -185,39 +252,34 @@ func (s *Server) handleCreateCategory(c *gin.Context) {
 		respondErrorMessage(c, http.StatusBadRequest, "name is required")
 		return
 	}
+	parentID, ok := s.validateCategoryParent(c, 0, req.ParentID)
+	if !ok {
+		return
+	}
 
 	var category Category
-	query := `INSERT INTO categories (name) VALUES ($1)
+	query := `INSERT INTO categories (name, parent_id) VALUES ($1, $2)
 		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
-		RETURNING id, name, created_at`
+		RETURNING id, name, parent_id, created_at, (xmax = 0)`
 	var categoryID int64
-	if err := s.db.QueryRow(query, strings.TrimSpace(req.Name)).Scan(&categoryID, &category.Name, &category.CreatedAt); err != nil {
+	var storedParentID sql.NullInt64
+	var inserted bool
+	if err := s.db.QueryRow(query, strings.TrimSpace(req.Name), parentID).Scan(&categoryID, &category.Name, &storedParentID, &category.CreatedAt, &inserted); err != nil {
 		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 	category.ID = formatID(categoryID)
-
-	respondSuccess(c, http.StatusCreated, category)
-}
-
-func (s *Server) handleDeleteCategory(c *gin.Context) {
-	categoryID, err := parseIDParam(c.Param("id"))
-	if err != nil {
-		respondErrorMessage(c, http.StatusBadRequest, "invalid category id")
-		return
+	category.ParentID = formatNullableID(storedParentID)
+	requestedParentID := sql.NullInt64{}
+	if parentID != nil {
+		requestedParentID = sql.NullInt64{Int64: *parentID, Valid: true}
 	}
-
-	result, err := s.db.Exec(`DELETE FROM categories WHERE id = $1`, categoryID)
-	if err != nil {
-		respondError(c, http.StatusInternalServerError, err)
-		return
-	}
-	if count, _ := result.RowsAffected(); count == 0 {
-		respondErrorMessage(c, http.StatusNotFound, "not found")
+	if !inserted && req.ParentID != nil && requestedParentID != storedParentID {
+		respondErrorData(c, http.StatusConflict, "category already exists with a different parent", category)
 		return
 	}
 
-	respondSuccess(c, http.StatusOK, gin.H{"status": "ok"})
+	respondSuccess(c, http.StatusCreated, category)
 }
 
 func (s *Server) handleListFeeds(c *gin.Context) {
@@ -231,7 +293,7 @@ func (s *Server) handleListFeeds(c *gin.Context) {
 			return
 		}
 		rows, err = s.db.Query(`
-			SELECT f.id, f.name, f.url, f.category_id, f.fetch_interval_minutes, f.last_fetched_at, f.last_status, f.last_error,
+			SELECT f.id, f.name, f.url, f.site_url, f.language, f.category_id, f.fetch_interval_minutes, f.last_fetched_at, f.last_status, f.last_error, f.last_warning,
 				c.name
 			FROM feeds f
 			LEFT JOIN categories c ON c.id = f.category_id
@@ -240,7 +302,7 @@ func (s *Server) handleListFeeds(c *gin.Context) {
 		`, categoryID)
 	} else {
 		rows, err = s.db.Query(`
-			SELECT f.id, f.name, f.url, f.category_id, f.fetch_interval_minutes, f.last_fetched_at, f.last_status, f.last_error,
+			SELECT f.id, f.name, f.url, f.site_url, f.language, f.category_id, f.fetch_interval_minutes, f.last_fetched_at, f.last_status, f.last_error, f.last_warning,
 				c.name
 			FROM feeds f
 			LEFT JOIN categories c ON c.id = f.category_id
@@ -262,11 +324,14 @@ func (s *Server) handleListFeeds(c *gin.Context) {
 			&feedID,
 			&feed.Name,
 			&feed.URL,
+			&feed.SiteURL,
+			&feed.Language,
 			&categoryID,
 			&feed.FetchInterval,
 			&feed.LastFetchedAt,
 			&feed.LastStatus,
 			&feed.LastError,
+			&feed.LastWarning,
 			&feed.CategoryName,
 		); err != nil {
 			respondError(c, http.StatusInternalServerError, err)
@@ -302,7 +367,7 @@ func (s *Server) handleCreateFeed(c *gin.Context) {
 		INSERT INTO feeds (name, url, category_id, fetch_interval_minutes)
 		VALUES ($1, $2, $3, $4)
 		ON CONFLICT (url) DO UPDATE SET name = EXCLUDED.name, category_id = EXCLUDED.category_id
-		RETURNING id, name, url, category_id, fetch_interval_minutes, last_fetched_at, last_status, last_error
+		RETURNING id, name, url, site_url, language, category_id, fetch_interval_minutes, last_fetched_at, last_status, last_error, last_warning
 	`
 	var feedID int64
 	var scannedCategoryID sql.NullInt64
@@ -310,11 +375,14 @@ func (s *Server) handleCreateFeed(c *gin.Context) {
 		&feedID,
 		&feed.Name,
 		&feed.URL,
+		&feed.SiteURL,
+		&feed.Language,
 		&scannedCategoryID,
 		&feed.FetchInterval,
 		&feed.LastFetchedAt,
 		&feed.LastStatus,
 		&feed.LastError,
+		&feed.LastWarning,
 	); err != nil {
 		respondError(c, http.StatusInternalServerError, err)
 		return
@@ -372,26 +440,45 @@ func (s *Server) handleUpdateFeed(c *gin.Context) {
 		}
 	}
 
+	if req.Language != nil {
+		setClauses = append(setClauses, "language = $"+strconv.Itoa(argIndex), "search_config = $"+strconv.Itoa(argIndex+1)+"::regconfig")
+		args = append(args, languageValue(*req.Language), textSearchConfig(*req.Language))
+		argIndex += 2
+	}
+
 	if len(setClauses) == 0 {
 		respondErrorMessage(c, http.StatusBadRequest, "no fields to update")
 		return
 	}
 
 	args = append(args, feedID)
-	query := `UPDATE feeds SET ` + strings.Join(setClauses, ", ") + ` WHERE id = $` + strconv.Itoa(argIndex) + ` RETURNING id, name, url, category_id, fetch_interval_minutes, last_fetched_at, last_status, last_error`
+	query := `UPDATE feeds SET ` + strings.Join(setClauses, ", ") + ` WHERE id = $` + strconv.Itoa(argIndex) + ` RETURNING id, name, url, site_url, language, category_id, fetch_interval_minutes, last_fetched_at, last_status, last_error, last_warning`
+
+	ctx := c.Request.Context()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
 
 	var feed Feed
 	var updatedFeedID int64
 	var categoryID sql.NullInt64
-	if err := s.db.QueryRow(query, args...).Scan(
+	if err := tx.QueryRowContext(ctx, query, args...).Scan(
 		&updatedFeedID,
 		&feed.Name,
 		&feed.URL,
+		&feed.SiteURL,
+		&feed.Language,
 		&categoryID,
 		&feed.FetchInterval,
 		&feed.LastFetchedAt,
 		&feed.LastStatus,
 		&feed.LastError,
+		&feed.LastWarning,
 	); err != nil {
 		respondError(c, http.StatusInternalServerError, err)
 		return
@@ -399,6 +486,17 @@ func (s *Server) handleUpdateFeed(c *gin.Context) {
 	feed.ID = formatID(updatedFeedID)
 	feed.CategoryID = formatNullableID(categoryID)
 
+	if req.Language != nil {
+		if _, err := tx.ExecContext(ctx, reindexFeedSearchQuery, updatedFeedID); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
 	respondSuccess(c, http.StatusOK, feed)
 }
 
@@ -446,7 +544,12 @@ func (s *Server) handleRefreshAll(c *gin.Context) {
 }
 
 func (s *Server) handleExportData(c *gin.Context) {
-	categoryRows, err := s.db.Query(`SELECT id, name FROM categories ORDER BY name ASC`)
+	categoryRows, err := s.db.Query(`
+		SELECT c.id, c.name, c.parent_id, p.name
+		FROM categories c
+		LEFT JOIN categories p ON p.id = c.parent_id
+		ORDER BY c.name ASC
+	`)
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, err)
 		return
@@ -457,16 +560,18 @@ func (s *Server) handleExportData(c *gin.Context) {
 	for categoryRows.Next() {
 		var category TransferCategory
 		var categoryID int64
-		if err := categoryRows.Scan(&categoryID, &category.Name); err != nil {
+		var parentID sql.NullInt64
+		if err := categoryRows.Scan(&categoryID, &category.Name, &parentID, &category.ParentName); err != nil {
 			respondError(c, http.StatusInternalServerError, err)
 			return
 		}
 		category.ID = formatID(categoryID)
+		category.ParentID = formatNullableID(parentID)
 		categories = append(categories, category)
 	}
 
 	feedRows, err := s.db.Query(`
-		SELECT f.id, f.name, f.url, f.category_id, c.name
+		SELECT f.id, f.name, f.url, f.site_url, f.category_id, c.name, f.fetch_interval_minutes
 		FROM feeds f
 		LEFT JOIN categories c ON c.id = f.category_id
 		ORDER BY f.name ASC
@@ -482,7 +587,7 @@ func (s *Server) handleExportData(c *gin.Context) {
 		var feed TransferFeed
 		var feedID int64
 		var categoryID sql.NullInt64
-		if err := feedRows.Scan(&feedID, &feed.Name, &feed.URL, &categoryID, &feed.CategoryName); err != nil {
+		if err := feedRows.Scan(&feedID, &feed.Name, &feed.URL, &feed.SiteURL, &categoryID, &feed.CategoryName, &feed.FetchInterval); err != nil {
 			respondError(c, http.StatusInternalServerError, err)
 			return
 		}
@@ -495,193 +600,120 @@ func (s *Server) handleExportData(c *gin.Context) {
 }
 
 func (s *Server) handleImportData(c *gin.Context) {
-	var payload TransferPayload
-	if err := c.ShouldBindJSON(&payload); err != nil {
-		respondError(c, http.StatusBadRequest, err)
+	strategy := c.DefaultQuery("strategy", importStrategyOverwrite)
+	if !isValidImportStrategy(strategy) {
+		respondErrorMessage(c, http.StatusBadRequest, "strategy must be overwrite, keep_existing or fail_on_conflict")
 		return
 	}
+	dryRun := c.Query("dry_run") == "true"
 
-	tx, err := s.db.BeginTx(c.Request.Context(), nil)
+	body, err := c.GetRawData()
 	if err != nil {
-		respondError(c, http.StatusInternalServerError, err)
+		respondError(c, http.StatusBadRequest, err)
 		return
 	}
 
-	rollback := func() {
-		_ = tx.Rollback()
-	}
-
-	categoryNameByID := make(map[string]string)
-	for _, category := range payload.Categories {
-		if strings.TrimSpace(category.ID) == "" {
-			continue
+	var set importSet
+	if isOPMLDocument(body) {
+		set, err = parseOPMLImport(body)
+	} else {
+		var payload TransferPayload
+		if err = json.Unmarshal(body, &payload); err == nil {
+			set, err = transferImportSet(payload)
 		}
-		categoryNameByID[strings.TrimSpace(category.ID)] = strings.TrimSpace(category.Name)
 	}
-
-	categoryIDByName := make(map[string]int64)
-	for _, category := range payload.Categories {
-		name := strings.TrimSpace(category.Name)
-		if name == "" {
-			rollback()
-			respondErrorMessage(c, http.StatusBadRequest, "category name is required")
-			return
-		}
-		var categoryID int64
-		if err := tx.QueryRow(
-			`INSERT INTO categories (name) VALUES ($1)
-			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
-			RETURNING id`,
-			name,
-		).Scan(&categoryID); err != nil {
-			rollback()
-			respondError(c, http.StatusInternalServerError, err)
-			return
-		}
-		categoryIDByName[name] = categoryID
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
 	}
 
-	importedFeeds := 0
-	for _, feed := range payload.Feeds {
-		name := strings.TrimSpace(feed.Name)
-		url := strings.TrimSpace(feed.URL)
-		if name == "" || url == "" {
-			rollback()
-			respondErrorMessage(c, http.StatusBadRequest, "feed name and url are required")
-			return
-		}
-
-		categoryName := ""
-		if feed.CategoryName != nil {
-			categoryName = strings.TrimSpace(*feed.CategoryName)
-		}
-		if categoryName == "" && feed.CategoryID != nil {
-			if mappedName, ok := categoryNameByID[strings.TrimSpace(*feed.CategoryID)]; ok {
-				categoryName = strings.TrimSpace(mappedName)
-			}
-		}
-
-		var categoryID *int64
-		if categoryName != "" {
-			if existingID, ok := categoryIDByName[categoryName]; ok {
-				categoryID = &existingID
-			} else {
-				var newCategoryID int64
-				if err := tx.QueryRow(
-					`INSERT INTO categories (name) VALUES ($1)
-					ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
-					RETURNING id`,
-					categoryName,
-				).Scan(&newCategoryID); err != nil {
-					rollback()
-					respondError(c, http.StatusInternalServerError, err)
-					return
-				}
-				categoryIDByName[categoryName] = newCategoryID
-				categoryID = &newCategoryID
-			}
-		}
-
-		var feedID int64
-		if err := tx.QueryRow(
-			`INSERT INTO feeds (name, url, category_id, fetch_interval_minutes)
-			VALUES ($1, $2, $3, $4)
-			ON CONFLICT (url) DO UPDATE SET name = EXCLUDED.name, category_id = EXCLUDED.category_id
-			RETURNING id`,
-			name,
-			url,
-			categoryID,
-			s.config.FetchIntervalMinutes,
-		).Scan(&feedID); err != nil {
-			rollback()
+	if dryRun {
+		report, err := s.previewImport(c.Request.Context(), set, strategy)
+		if err != nil {
 			respondError(c, http.StatusInternalServerError, err)
 			return
 		}
-		importedFeeds++
+		respondSuccess(c, http.StatusOK, report)
+		return
 	}
 
-	if err := tx.Commit(); err != nil {
-		rollback()
+	report, err := s.applyImport(c.Request.Context(), set, strategy)
+	var conflict importConflictError
+	if errors.As(err, &conflict) {
+		respondErrorData(c, http.StatusConflict, conflict.Error(), conflict.report)
+		return
+	}
+	if err != nil {
 		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
 
-	respondSuccess(c, http.StatusOK, gin.H{
-		"categories": len(payload.Categories),
-		"feeds":      importedFeeds,
-	})
+	respondSuccess(c, http.StatusOK, report)
 }
 
 func (s *Server) handleListItems(c *gin.Context) {
-	categoryIDParam := c.Query("category_id")
-	feedIDParam := c.Query("feed_id")
-	searchQuery := strings.TrimSpace(c.Query("q"))
-	unreadOnly := c.Query("unread") == "true"
-	favoriteOnly := c.Query("favorite") == "true"
 	page := parsePositiveInt(c.Query("page"), 1)
 	pageSize := parsePositiveInt(c.Query("page_size"), 20)
 	offset := (page - 1) * pageSize
+	cursorParam, cursorMode := c.GetQuery("cursor")
+	withTotal := !cursorMode
+	if value, ok := c.GetQuery("with_total"); ok {
+		withTotal = value == "true"
+	}
 
-	conditions := []string{}
-	args := []interface{}{}
-	argIndex := 1
-	if categoryIDParam != "" {
-		categoryID, err := parseIDParam(categoryIDParam)
-		if err != nil {
-			respondErrorMessage(c, http.StatusBadRequest, "invalid category id")
-			return
-		}
-		conditions = append(conditions, "f.category_id = $"+strconv.Itoa(argIndex))
-		args = append(args, categoryID)
-		argIndex++
+	if cursorMode {
+		page = 0
+		offset = 0
+	}
+
+	listQuery, ok := s.itemListQueryFromRequest(c)
+	if !ok {
+		return
 	}
-	if feedIDParam != "" {
-		feedID, err := parseIDParam(feedIDParam)
+	argIndex := listQuery.ArgIndex
+	order := listQuery.Order
+
+	var cursor *itemCursor
+	if cursorParam != "" {
+		decoded, err := decodeItemCursor(cursorParam, order)
 		if err != nil {
-			respondErrorMessage(c, http.StatusBadRequest, "invalid feed id")
+			respondError(c, http.StatusBadRequest, err)
 			return
 		}
-		conditions = append(conditions, "i.feed_id = $"+strconv.Itoa(argIndex))
-		args = append(args, feedID)
-		argIndex++
-	}
-	if searchQuery != "" {
-		conditions = append(conditions, "(i.title ILIKE $"+strconv.Itoa(argIndex)+" OR i.summary ILIKE $"+strconv.Itoa(argIndex)+")")
-		args = append(args, "%"+searchQuery+"%")
-		argIndex++
-	}
-	if unreadOnly {
-		conditions = append(conditions, "i.is_read = FALSE")
-	}
-	if favoriteOnly {
-		conditions = append(conditions, "i.is_favorite = TRUE")
+		cursor = &decoded
 	}
 
-	whereClause := ""
-	if len(conditions) > 0 {
-		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	var total *int
+	if withTotal {
+		var count int
+		countQuery := `SELECT COUNT(*) FROM items i JOIN feeds f ON f.id = i.feed_id ` + listQuery.where()
+		if err := s.db.QueryRow(countQuery, listQuery.FilterArgs...).Scan(&count); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		total = &count
 	}
 
-	var total int
-	countQuery := `SELECT COUNT(*) FROM items i JOIN feeds f ON f.id = i.feed_id ` + whereClause
-	if err := s.db.QueryRow(countQuery, args...).Scan(&total); err != nil {
-		respondError(c, http.StatusInternalServerError, err)
-		return
+	listArgs := append([]interface{}{}, listQuery.Args...)
+	listWhereClause := listQuery.where()
+	backward := cursor != nil && cursor.Backward
+	if cursor != nil {
+		keyset, keysetArgs, nextIndex := order.keysetCondition(*cursor, argIndex)
+		listWhereClause = listQuery.where(keyset)
+		listArgs = append(listArgs, keysetArgs...)
+		argIndex = nextIndex
 	}
-
-	var rows *sql.Rows
-	var err error
-	listArgs := append([]interface{}{}, args...)
-	listArgs = append(listArgs, pageSize, offset)
+	listArgs = append(listArgs, pageSize+1, offset)
 	limitIndex := argIndex
 	offsetIndex := argIndex + 1
-	rows, err = s.db.Query(`
-		SELECT i.id, i.feed_id, f.name, f.category_id, c.name, i.title, i.link, COALESCE(i.summary, ''), i.published_at, i.is_read, i.is_favorite
+	rows, err := s.db.Query(`
+		SELECT i.id, i.feed_id, f.name, f.category_id, c.name, i.title, i.link, COALESCE(i.summary, ''), i.published_at, i.is_read, i.is_favorite,
+			`+itemTagNamesColumn+`, `+listQuery.RankColumn+`, `+listQuery.SnippetColumn+`, `+order.selectList()+`
 		FROM items i
 		JOIN feeds f ON f.id = i.feed_id
 		LEFT JOIN categories c ON c.id = f.category_id
-		`+whereClause+`
-		ORDER BY i.published_at DESC NULLS LAST, i.created_at DESC
+		`+listWhereClause+`
+		ORDER BY `+order.clause(backward)+`
 		LIMIT $`+strconv.Itoa(limitIndex)+` OFFSET $`+strconv.Itoa(offsetIndex)+`
 	`, listArgs...)
 	if err != nil {
@@ -691,12 +723,14 @@ func (s *Server) handleListItems(c *gin.Context) {
 	defer rows.Close()
 
 	items := make([]Item, 0)
+	positions := make([][]sql.NullString, 0)
 	for rows.Next() {
 		var item Item
 		var itemID int64
 		var feedID int64
 		var categoryID sql.NullInt64
-		if err := rows.Scan(
+		sortTargets, sortValues := order.scanTargets()
+		targets := append([]interface{}{
 			&itemID,
 			&feedID,
 			&item.FeedName,
@@ -708,7 +742,11 @@ func (s *Server) handleListItems(c *gin.Context) {
 			&item.PublishedAt,
 			&item.IsRead,
 			&item.IsFavorite,
-		); err != nil {
+			pq.Array(&item.Tags),
+			&item.Rank,
+			&item.Snippet,
+		}, sortTargets...)
+		if err := rows.Scan(targets...); err != nil {
 			respondError(c, http.StatusInternalServerError, err)
 			return
 		}
@@ -716,47 +754,58 @@ func (s *Server) handleListItems(c *gin.Context) {
 		item.FeedID = formatID(feedID)
 		item.CategoryID = formatNullableID(categoryID)
 		items = append(items, item)
+		positions = append(positions, sortValues)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
 	}
 
-	respondSuccess(c, http.StatusOK, ItemsResponse{Items: items, Total: total, Page: page, PageSize: pageSize})
-}
-
-func (s *Server) handleUnreadCount(c *gin.Context) {
-	categoryIDParam := c.Query("category_id")
-	feedIDParam := c.Query("feed_id")
-	conditions := []string{"i.is_read = FALSE"}
-	args := []interface{}{}
-	argIndex := 1
-
-	if categoryIDParam != "" {
-		categoryID, err := parseIDParam(categoryIDParam)
-		if err != nil {
-			respondErrorMessage(c, http.StatusBadRequest, "invalid category id")
-			return
+	hasMore := len(items) > pageSize
+	if hasMore {
+		items = items[:pageSize]
+		positions = positions[:pageSize]
+	}
+	if backward {
+		for left, right := 0, len(items)-1; left < right; left, right = left+1, right-1 {
+			items[left], items[right] = items[right], items[left]
+			positions[left], positions[right] = positions[right], positions[left]
 		}
-		conditions = append(conditions, "f.category_id = $"+strconv.Itoa(argIndex))
-		args = append(args, categoryID)
-		argIndex++
 	}
-	if feedIDParam != "" {
-		feedID, err := parseIDParam(feedIDParam)
-		if err != nil {
-			respondErrorMessage(c, http.StatusBadRequest, "invalid feed id")
-			return
+
+	response := ItemsResponse{Items: items, Total: total, Page: page, PageSize: pageSize}
+	if len(positions) > 0 {
+		first := positions[0]
+		last := positions[len(positions)-1]
+		if hasMore || backward {
+			response.NextCursor = encodeItemCursor(order, last, false)
+		}
+		if (backward && hasMore) || (!backward && (cursor != nil || page > 1)) {
+			response.PrevCursor = encodeItemCursor(order, first, true)
 		}
-		conditions = append(conditions, "i.feed_id = $"+strconv.Itoa(argIndex))
-		args = append(args, feedID)
-		argIndex++
 	}
 
-	whereClause := "WHERE " + strings.Join(conditions, " AND ")
-	query := `SELECT COUNT(*) FROM items i JOIN feeds f ON f.id = i.feed_id ` + whereClause
-	var count int
-	if err := s.db.QueryRow(query, args...).Scan(&count); err != nil {
+	respondSuccess(c, http.StatusOK, response)
+}
+
+func (s *Server) handleUnreadCount(c *gin.Context) {
+	filter, err := itemFilterFromQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	count, folderCounts, err := s.savedSearchUnreadCounts(c.Request.Context(), filter)
+	var queryErr searchQueryError
+	if errors.As(err, &queryErr) {
+		respondErrorData(c, http.StatusBadRequest, queryErr.Error(), queryErr)
+		return
+	}
+	if err != nil {
 		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
-	respondSuccess(c, http.StatusOK, gin.H{"unread": count})
+	respondSuccess(c, http.StatusOK, gin.H{"unread": count, "saved_searches": folderCounts})
 }
 
 func (s *Server) handleUpdateItemRead(c *gin.Context) {
@@ -772,12 +821,26 @@ func (s *Server) handleUpdateItemRead(c *gin.Context) {
 		return
 	}
 
-	result, err := s.db.Exec(`UPDATE items SET is_read = $1 WHERE id = $2`, req.Read, itemID)
+	var count int
+	err = s.db.QueryRow(`
+		WITH target AS (
+			SELECT id, is_read FROM items WHERE id = $2 FOR UPDATE
+		), changed AS (
+			UPDATE items i
+			SET is_read = $1, read_at = CASE WHEN $1 THEN COALESCE(i.read_at, NOW()) END
+			FROM target t
+			WHERE i.id = t.id
+			RETURNING i.feed_id, t.is_read AS was_read, i.is_read
+		), counted AS (
+			`+feedCounterChange("changed", "was_read::integer - is_read::integer", "0", "0")+`
+		)
+		SELECT COUNT(*) FROM target
+	`, req.Read, itemID).Scan(&count)
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
-	if count, _ := result.RowsAffected(); count == 0 {
+	if count == 0 {
 		respondErrorMessage(c, http.StatusNotFound, "not found")
 		return
 	}
@@ -802,12 +865,95 @@ func (s *Server) handleBatchRead(c *gin.Context) {
 		return
 	}
 
-	query := `UPDATE items SET is_read = $1 WHERE id = ANY($2)`
-	if _, err := s.db.Exec(query, req.Read, pqArray(itemIDs)); err != nil {
+	operation, err := s.setReadState(c.Request.Context(), s.db, "read-batch", req.Read, []string{"i.id = ANY($1)"}, []interface{}{pqArray(itemIDs)})
+	if err != nil {
 		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
-	respondSuccess(c, http.StatusOK, gin.H{"status": "ok"})
+	respondSuccess(c, http.StatusOK, operation)
+}
+
+func (s *Server) handleMarkRead(c *gin.Context) {
+	var req markReadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	var filter ItemFilter
+	scopeID, idErr := parseIDParam(strings.TrimSpace(req.ID))
+	switch req.Scope {
+	case "all":
+	case "feed":
+		if idErr != nil {
+			respondErrorMessage(c, http.StatusBadRequest, "invalid feed id")
+			return
+		}
+		filter.FeedID = &scopeID
+	case "category":
+		if idErr != nil {
+			respondErrorMessage(c, http.StatusBadRequest, "invalid category id")
+			return
+		}
+		filter.CategoryID = &scopeID
+	case "saved_search":
+		if idErr != nil {
+			respondErrorMessage(c, http.StatusBadRequest, "invalid saved search id")
+			return
+		}
+		savedSearch, err := s.loadSavedSearch(c.Request.Context(), scopeID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondErrorMessage(c, http.StatusNotFound, "saved search not found")
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		filter = savedSearch.filter()
+	default:
+		respondErrorMessage(c, http.StatusBadRequest, "scope must be feed, category, saved_search or all")
+		return
+	}
+
+	conditions, args, argIndex, _, err := buildItemConditions(filter, 1)
+	var queryErr searchQueryError
+	if errors.As(err, &queryErr) {
+		respondErrorData(c, http.StatusBadRequest, queryErr.Error(), queryErr)
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.OlderThan != nil {
+		olderThan, err := parseFilterTime(*req.OlderThan)
+		if err != nil {
+			respondErrorMessage(c, http.StatusBadRequest, "invalid older_than")
+			return
+		}
+		if olderThan != nil {
+			conditions = append(conditions, "COALESCE(i.published_at, i.created_at) < $"+strconv.Itoa(argIndex))
+			args = append(args, *olderThan)
+			argIndex++
+		}
+	}
+	if req.BeforeID != nil {
+		beforeID, err := parseIDParam(strings.TrimSpace(*req.BeforeID))
+		if err != nil {
+			respondErrorMessage(c, http.StatusBadRequest, "invalid before_id")
+			return
+		}
+		conditions = append(conditions, "i.id <= $"+strconv.Itoa(argIndex))
+		args = append(args, beforeID)
+	}
+
+	operation, err := s.setReadState(c.Request.Context(), s.db, "mark-read:"+req.Scope, true, conditions, args)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, operation)
 }
 
 func (s *Server) handleUpdateItemFavorite(c *gin.Context) {
@@ -823,12 +969,25 @@ func (s *Server) handleUpdateItemFavorite(c *gin.Context) {
 		return
 	}
 
-	result, err := s.db.Exec(`UPDATE items SET is_favorite = $1 WHERE id = $2`, req.Favorite, itemID)
+	var count int
+	err = s.db.QueryRow(`
+		WITH target AS (
+			SELECT id, is_favorite FROM items WHERE id = $2 FOR UPDATE
+		), changed AS (
+			UPDATE items i SET is_favorite = $1
+			FROM target t
+			WHERE i.id = t.id
+			RETURNING i.feed_id, t.is_favorite AS was_favorite, i.is_favorite
+		), counted AS (
+			`+feedCounterChange("changed", "0", "is_favorite::integer - was_favorite::integer", "0")+`
+		)
+		SELECT COUNT(*) FROM target
+	`, req.Favorite, itemID).Scan(&count)
 	if err != nil {
 		respondError(c, http.StatusInternalServerError, err)
 		return
 	}
-	if count, _ := result.RowsAffected(); count == 0 {
+	if count == 0 {
 		respondErrorMessage(c, http.StatusNotFound, "not found")
 		return
 	}
@@ -938,12 +1097,20 @@ func (s *Server) handleDeleteReadLater(c *gin.Context) {
 	respondSuccess(c, http.StatusOK, gin.H{"status": "ok"})
 }
 
+func recoverPanic(c *gin.Context, err any) {
+	if err == http.ErrAbortHandler {
+		panic(err)
+	}
+	c.AbortWithStatus(http.StatusInternalServerError)
+}
+
 func corsMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		headers := c.Writer.Header()
 		headers.Set("Access-Control-Allow-Origin", "*")
 		headers.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS, PATCH, DELETE")
-		headers.Set("Access-Control-Allow-Headers", "Content-Type")
+		headers.Set("Access-Control-Allow-Headers", "Content-Type, If-None-Match")
+		headers.Set("Access-Control-Expose-Headers", "ETag")
 		if c.Request.Method == http.MethodOptions {
 			c.AbortWithStatus(http.StatusNoContent)
 			return
@@ -991,9 +1158,13 @@ func respondSuccess(c *gin.Context, status int, data interface{}) {
 }
 
 func respondErrorMessage(c *gin.Context, status int, message string) {
+	respondErrorData(c, status, message, nil)
+}
+
+func respondErrorData(c *gin.Context, status int, message string, data interface{}) {
 	c.Set("response_status", status)
 	c.Set("response_message", message)
-	c.Set("response_data", nil)
+	c.Set("response_data", data)
 	c.Abort()
 }
 
@@ -1057,3 +1228,8 @@ func parsePositiveInt(value string, fallback int) int {
 func pqArray(values []int64) interface{} {
 	return pq.Array(values)
 }
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
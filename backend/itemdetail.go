@@ -0,0 +1,132 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+type ItemDetail struct {
+	Item
+	Content       *string         `json:"content"`
+	Author        *string         `json:"author"`
+	Enclosures    json.RawMessage `json:"enclosures"`
+	FeedSiteURL   *string         `json:"feed_site_url"`
+	FetchedAt     time.Time       `json:"fetched_at"`
+	ReadLaterAt   *time.Time      `json:"read_later_at"`
+	MatchesFilter bool            `json:"matches_filter"`
+	PrevID        *string         `json:"prev_id"`
+	NextID        *string         `json:"next_id"`
+}
+
+func (s *Server) handleGetItem(c *gin.Context) {
+	itemID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid item id")
+		return
+	}
+	listQuery, ok := s.itemListQueryFromRequest(c)
+	if !ok {
+		return
+	}
+	order := listQuery.Order
+
+	var detail ItemDetail
+	var feedID int64
+	var categoryID sql.NullInt64
+	var enclosures []byte
+	sortTargets, sortValues := order.scanTargets()
+	targets := append([]interface{}{
+		&feedID,
+		&detail.FeedName,
+		&categoryID,
+		&detail.Category,
+		&detail.FeedSiteURL,
+		&detail.Title,
+		&detail.Link,
+		&detail.Summary,
+		&detail.Content,
+		&detail.Author,
+		&enclosures,
+		&detail.PublishedAt,
+		&detail.FetchedAt,
+		&detail.IsRead,
+		&detail.IsFavorite,
+		&detail.ReadLaterAt,
+		pq.Array(&detail.Tags),
+		&detail.MatchesFilter,
+		&detail.Rank,
+		&detail.Snippet,
+	}, sortTargets...)
+	err = s.db.QueryRow(`
+		SELECT i.feed_id, f.name, f.category_id, c.name, f.site_url, i.title, i.link, COALESCE(i.summary, ''), NULLIF(i.content, ''), i.author,
+			i.enclosures, i.published_at, i.created_at, i.is_read, i.is_favorite, rl.created_at, `+itemTagNamesColumn+`,
+			COALESCE(`+listQuery.condition()+`, FALSE), `+listQuery.RankColumn+`, `+listQuery.SnippetColumn+`, `+order.selectList()+`
+		FROM items i
+		JOIN feeds f ON f.id = i.feed_id
+		LEFT JOIN categories c ON c.id = f.category_id
+		LEFT JOIN read_later rl ON rl.item_id = i.id
+		WHERE i.id = $`+strconv.Itoa(listQuery.ArgIndex), append(append([]interface{}{}, listQuery.Args...), itemID)...).Scan(targets...)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	detail.ID = formatID(itemID)
+	detail.FeedID = formatID(feedID)
+	detail.CategoryID = formatNullableID(categoryID)
+	detail.Enclosures = json.RawMessage(enclosures)
+
+	for _, backward := range []bool{false, true} {
+		cursor := itemCursor{Sort: order.Name, Values: make([]*string, len(sortValues)), Backward: backward}
+		for index, value := range sortValues {
+			if value.Valid {
+				text := value.String
+				cursor.Values[index] = &text
+			}
+		}
+		query, args := listQuery.neighborQuery(cursor)
+		var neighborID int64
+		err := s.db.QueryRow(query, args...).Scan(&neighborID)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		formatted := formatID(neighborID)
+		if backward {
+			detail.PrevID = &formatted
+		} else {
+			detail.NextID = &formatted
+		}
+	}
+
+	respondSuccess(c, http.StatusOK, detail)
+}
+
+func (q itemListQuery) neighborQuery(cursor itemCursor) (string, []interface{}) {
+	args, argIndex := q.FilterArgs, len(q.FilterArgs)+1
+	if q.Order.Name == "relevance" {
+		args, argIndex = q.Args, q.ArgIndex
+	}
+	keyset, keysetArgs, _ := q.Order.keysetCondition(cursor, argIndex)
+	return `
+		SELECT i.id
+		FROM items i
+		JOIN feeds f ON f.id = i.feed_id
+		` + q.where(keyset) + `
+		ORDER BY ` + q.Order.clause(cursor.Backward) + `
+		LIMIT 1
+	`, append(append([]interface{}{}, args...), keysetArgs...)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+var placeholderPattern = regexp.MustCompile(`\$(\d+)`)
+
+func TestNeighborQueryUsesEveryArg(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := []string{
+		"sort=newest",
+		"q=golang&sort=newest",
+		"q=golang&sort=oldest",
+		"q=golang&sort=feed",
+		"q=golang&sort=fetched",
+		"q=golang",
+		"q=golang+is:unread&sort=relevance",
+	}
+
+	for _, rawQuery := range tests {
+		t.Run(rawQuery, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(recorder)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/items/1?"+rawQuery, nil)
+			listQuery, ok := (&Server{}).itemListQueryFromRequest(c)
+			if !ok {
+				t.Fatalf("itemListQueryFromRequest() rejected %q: %s", rawQuery, recorder.Body.String())
+			}
+
+			values := make([]*string, len(listQuery.Order.Keys))
+			for index := range values {
+				values[index] = stringPointer("v" + strconv.Itoa(index))
+			}
+			for _, backward := range []bool{false, true} {
+				cursor := itemCursor{Sort: listQuery.Order.Name, Values: values, Backward: backward}
+				query, args := listQuery.neighborQuery(cursor)
+
+				used := map[int]bool{}
+				for _, match := range placeholderPattern.FindAllStringSubmatch(query, -1) {
+					index, _ := strconv.Atoi(match[1])
+					used[index] = true
+				}
+				if len(used) != len(args) {
+					t.Fatalf("query references %d params, passes %d args:\n%s", len(used), len(args), query)
+				}
+				for index := 1; index <= len(args); index++ {
+					if !used[index] {
+						t.Fatalf("param $%d is passed but not referenced:\n%s", index, query)
+					}
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,270 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ItemFilter struct {
+	CategoryID *int64
+	FeedID     *int64
+	Tag        string
+	Queries    []string
+	Unread     bool
+	Favorite   bool
+	Since      *time.Time
+	Until      *time.Time
+	DateField  string
+}
+
+func itemFilterFromQuery(c *gin.Context) (ItemFilter, error) {
+	filter := ItemFilter{
+		Unread:   c.Query("unread") == "true",
+		Favorite: c.Query("favorite") == "true",
+	}
+	if value := c.Query("category_id"); value != "" {
+		categoryID, err := parseIDParam(value)
+		if err != nil {
+			return filter, errors.New("invalid category id")
+		}
+		filter.CategoryID = &categoryID
+	}
+	if value := c.Query("feed_id"); value != "" {
+		feedID, err := parseIDParam(value)
+		if err != nil {
+			return filter, errors.New("invalid feed id")
+		}
+		filter.FeedID = &feedID
+	}
+	filter.Tag = strings.TrimSpace(c.Query("tag"))
+	if query := strings.TrimSpace(c.Query("q")); query != "" {
+		filter.Queries = []string{query}
+	}
+	since, err := parseFilterTime(c.Query("since"))
+	if err != nil {
+		return filter, errors.New("invalid since")
+	}
+	until, err := parseFilterTime(c.Query("until"))
+	if err != nil {
+		return filter, errors.New("invalid until")
+	}
+	filter.Since = since
+	filter.Until = until
+	switch dateField := c.Query("date_field"); dateField {
+	case "", "published", "fetched":
+		filter.DateField = dateField
+	default:
+		return filter, errors.New("date_field must be published or fetched")
+	}
+	return filter, nil
+}
+
+func parseFilterTime(value string) (*time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil, nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return &parsed, nil
+		}
+	}
+	return nil, errors.New("invalid time")
+}
+
+func (f ItemFilter) merge(override ItemFilter) ItemFilter {
+	merged := f
+	if override.CategoryID != nil {
+		merged.CategoryID = override.CategoryID
+	}
+	if override.FeedID != nil {
+		merged.FeedID = override.FeedID
+	}
+	if override.Tag != "" {
+		merged.Tag = override.Tag
+	}
+	merged.Queries = append(append([]string{}, f.Queries...), override.Queries...)
+	merged.Unread = f.Unread || override.Unread
+	merged.Favorite = f.Favorite || override.Favorite
+	if override.Since != nil {
+		merged.Since = override.Since
+	}
+	if override.Until != nil {
+		merged.Until = override.Until
+	}
+	if override.DateField != "" {
+		merged.DateField = override.DateField
+	}
+	return merged
+}
+
+func buildItemConditions(filter ItemFilter, argIndex int) ([]string, []interface{}, int, string, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	if filter.CategoryID != nil {
+		conditions = append(conditions, categoryTreeCondition("SELECT $"+strconv.Itoa(argIndex)+"::bigint"))
+		args = append(args, *filter.CategoryID)
+		argIndex++
+	}
+	if filter.FeedID != nil {
+		conditions = append(conditions, "i.feed_id = $"+strconv.Itoa(argIndex))
+		args = append(args, *filter.FeedID)
+		argIndex++
+	}
+	if filter.Tag != "" {
+		conditions = append(conditions, "EXISTS (SELECT 1 FROM item_tags it JOIN tags t ON t.id = it.tag_id WHERE it.item_id = i.id AND t.name = $"+strconv.Itoa(argIndex)+")")
+		args = append(args, filter.Tag)
+		argIndex++
+	}
+
+	rankTexts := make([]string, 0, len(filter.Queries))
+	for _, query := range filter.Queries {
+		parsedQuery, err := parseSearchQuery(query)
+		if err != nil {
+			return nil, nil, argIndex, "", err
+		}
+		condition, conditionArgs, nextIndex := parsedQuery.condition(argIndex)
+		if condition != "" {
+			conditions = append(conditions, condition)
+			args = append(args, conditionArgs...)
+			argIndex = nextIndex
+		}
+		if rankText := parsedQuery.rankText(); rankText != "" {
+			rankTexts = append(rankTexts, rankText)
+		}
+	}
+
+	if filter.Unread {
+		conditions = append(conditions, "i.is_read = FALSE")
+	}
+	if filter.Favorite {
+		conditions = append(conditions, "i.is_favorite = TRUE")
+	}
+	dateColumn := "i.published_at"
+	if filter.DateField == "fetched" {
+		dateColumn = "i.created_at"
+	}
+	if filter.Since != nil {
+		conditions = append(conditions, dateColumn+" >= $"+strconv.Itoa(argIndex))
+		args = append(args, *filter.Since)
+		argIndex++
+	}
+	if filter.Until != nil {
+		conditions = append(conditions, dateColumn+" < $"+strconv.Itoa(argIndex))
+		args = append(args, *filter.Until)
+		argIndex++
+	}
+	return conditions, args, argIndex, strings.Join(rankTexts, " or "), nil
+}
+
+type itemListQuery struct {
+	Conditions    []string
+	FilterArgs    []interface{}
+	Args          []interface{}
+	ArgIndex      int
+	Order         itemOrder
+	RankColumn    string
+	SnippetColumn string
+}
+
+func (q itemListQuery) condition() string {
+	if len(q.Conditions) == 0 {
+		return "TRUE"
+	}
+	return "(" + strings.Join(q.Conditions, " AND ") + ")"
+}
+
+func (q itemListQuery) where(extra ...string) string {
+	conditions := append(append([]string{}, q.Conditions...), extra...)
+	if len(conditions) == 0 {
+		return ""
+	}
+	return "WHERE " + strings.Join(conditions, " AND ")
+}
+
+func (s *Server) itemListQueryFromRequest(c *gin.Context) (itemListQuery, bool) {
+	filter, err := itemFilterFromQuery(c)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return itemListQuery{}, false
+	}
+	if value := c.Query("saved_search_id"); value != "" {
+		savedSearchID, err := parseIDParam(value)
+		if err != nil {
+			respondErrorMessage(c, http.StatusBadRequest, "invalid saved search id")
+			return itemListQuery{}, false
+		}
+		savedSearch, err := s.loadSavedSearch(c.Request.Context(), savedSearchID)
+		if errors.Is(err, sql.ErrNoRows) {
+			respondErrorMessage(c, http.StatusNotFound, "saved search not found")
+			return itemListQuery{}, false
+		}
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return itemListQuery{}, false
+		}
+		filter = savedSearch.filter().merge(filter)
+	}
+
+	conditions, args, argIndex, rankText, err := buildItemConditions(filter, 1)
+	var queryErr searchQueryError
+	if errors.As(err, &queryErr) {
+		respondErrorData(c, http.StatusBadRequest, queryErr.Error(), queryErr)
+		return itemListQuery{}, false
+	}
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return itemListQuery{}, false
+	}
+
+	sort := c.Query("sort")
+	if sort == "" {
+		sort = "newest"
+		if rankText != "" {
+			sort = "relevance"
+		}
+	}
+	if !itemSorts[sort] {
+		respondErrorMessage(c, http.StatusBadRequest, "sort must be newest, oldest, feed, fetched or relevance")
+		return itemListQuery{}, false
+	}
+	if sort == "relevance" && rankText == "" {
+		respondErrorMessage(c, http.StatusBadRequest, "relevance sort requires a text search in q")
+		return itemListQuery{}, false
+	}
+
+	query := itemListQuery{
+		Conditions:    conditions,
+		FilterArgs:    args,
+		Args:          args,
+		ArgIndex:      argIndex,
+		RankColumn:    "NULL::real",
+		SnippetColumn: "NULL::text",
+	}
+	if rankText != "" {
+		tsQuery := "websearch_to_tsquery(f.search_config, $" + strconv.Itoa(query.ArgIndex) + ")"
+		query.Args = append(append([]interface{}{}, args...), rankText)
+		query.ArgIndex++
+		query.RankColumn = "ts_rank(i.search_vector, " + tsQuery + ")"
+		query.SnippetColumn = "ts_headline(f.search_config, i.title || ' ' || COALESCE(i.summary, ''), " + tsQuery + ", '" + searchHeadlineOptions + "')"
+	}
+	switch sort {
+	case "oldest":
+		query.Order = oldestItemOrder()
+	case "feed":
+		query.Order = feedItemOrder()
+	case "fetched":
+		query.Order = fetchedItemOrder()
+	case "relevance":
+		query.Order = relevanceItemOrder(query.RankColumn)
+	default:
+		query.Order = newestItemOrder()
+	}
+	return query, true
+}
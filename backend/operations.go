@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type ReadOperation struct {
+	Updated     int64      `json:"updated"`
+	OperationID *string    `json:"operation_id"`
+	ExpiresAt   *time.Time `json:"undo_expires_at"`
+}
+
+func (s *Server) setReadState(ctx context.Context, q dbExecutor, kind string, read bool, conditions []string, args []interface{}) (ReadOperation, error) {
+	argIndex := len(args) + 1
+	readParam := "$" + strconv.Itoa(argIndex) + "::boolean"
+	kindParam := "$" + strconv.Itoa(argIndex+1) + "::text"
+	windowParam := "$" + strconv.Itoa(argIndex+2) + "::integer"
+	args = append(args, read, kind, s.config.UndoWindowMinutes)
+	conditions = append(conditions, "i.is_read <> "+readParam)
+
+	var operation ReadOperation
+	var operationID int64
+	var expiresAt time.Time
+	err := q.QueryRowContext(ctx, `
+		WITH changed AS (
+			UPDATE items i SET is_read = `+readParam+`, read_at = CASE WHEN `+readParam+` THEN COALESCE(i.read_at, NOW()) END
+			FROM feeds f
+			WHERE f.id = i.feed_id AND `+strings.Join(conditions, " AND ")+`
+			RETURNING i.id, i.feed_id, i.is_read
+		), counted AS (
+			`+feedCounterChange("changed", "CASE WHEN is_read THEN -1 ELSE 1 END", "0", "0")+`
+		), operation AS (
+			INSERT INTO operations (kind, item_count, expires_at)
+			SELECT `+kindParam+`, COUNT(*), NOW() + make_interval(mins => `+windowParam+`)
+			FROM changed
+			HAVING COUNT(*) > 0
+			RETURNING id, item_count, expires_at
+		), recorded AS (
+			INSERT INTO operation_items (operation_id, item_id, was_read)
+			SELECT operation.id, changed.id, NOT `+readParam+`
+			FROM operation, changed
+		)
+		SELECT id, item_count, expires_at FROM operation
+	`, args...).Scan(&operationID, &operation.Updated, &expiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return operation, nil
+	}
+	if err != nil {
+		return operation, err
+	}
+	formatted := formatID(operationID)
+	operation.OperationID = &formatted
+	operation.ExpiresAt = &expiresAt
+	return operation, nil
+}
+
+func (s *Server) handleUndoOperation(c *gin.Context) {
+	operationID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid operation id")
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var expired bool
+	var undoneAt *time.Time
+	err = tx.QueryRowContext(ctx, `
+		SELECT expires_at <= NOW(), undone_at FROM operations WHERE id = $1 FOR UPDATE
+	`, operationID).Scan(&expired, &undoneAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if undoneAt != nil {
+		respondErrorMessage(c, http.StatusConflict, "operation already undone")
+		return
+	}
+	if expired {
+		respondErrorMessage(c, http.StatusGone, "undo window has expired")
+		return
+	}
+
+	var restored int64
+	err = tx.QueryRowContext(ctx, `
+		WITH changed AS (
+			UPDATE items i SET is_read = oi.was_read, read_at = CASE WHEN oi.was_read THEN COALESCE(i.read_at, NOW()) END
+			FROM operation_items oi
+			WHERE oi.operation_id = $1 AND oi.item_id = i.id AND i.is_read <> oi.was_read
+			RETURNING i.feed_id, i.is_read
+		), counted AS (
+			`+feedCounterChange("changed", "CASE WHEN is_read THEN -1 ELSE 1 END", "0", "0")+`
+		)
+		SELECT COUNT(*) FROM changed
+	`, operationID).Scan(&restored)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE operations SET undone_at = NOW() WHERE id = $1`, operationID); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if err := tx.Commit(); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, gin.H{"restored": restored})
+}
+
+func (s *Server) cleanupOperations(ctx context.Context) {
+	result, err := s.db.ExecContext(ctx, `DELETE FROM operations WHERE expires_at <= NOW() OR undone_at IS NOT NULL`)
+	if err != nil {
+		log.Printf("cleanup operations: %v", err)
+		return
+	}
+	if count, _ := result.RowsAffected(); count > 0 {
+		log.Printf("removed %d expired undo operations", count)
+	}
+}
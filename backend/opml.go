@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type OPML struct {
+	XMLName xml.Name `xml:"opml"`
+	Version string   `xml:"version,attr"`
+	Head    OPMLHead `xml:"head"`
+	Body    OPMLBody `xml:"body"`
+}
+
+type OPMLHead struct {
+	Title       string `xml:"title,omitempty"`
+	DateCreated string `xml:"dateCreated,omitempty"`
+}
+
+type OPMLBody struct {
+	Outlines []OPMLOutline `xml:"outline"`
+}
+
+type OPMLOutline struct {
+	Text          string        `xml:"text,attr"`
+	Title         string        `xml:"title,attr,omitempty"`
+	Type          string        `xml:"type,attr,omitempty"`
+	XMLURL        string        `xml:"xmlUrl,attr,omitempty"`
+	HTMLURL       string        `xml:"htmlUrl,attr,omitempty"`
+	FetchInterval string        `xml:"fetchIntervalMinutes,attr,omitempty"`
+	Outlines      []OPMLOutline `xml:"outline"`
+}
+
+func isOPMLDocument(body []byte) bool {
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(body, utf8BOM))
+	return len(trimmed) > 0 && trimmed[0] == '<'
+}
+
+func parseOPMLImport(body []byte) (importSet, error) {
+	document, _, err := decodeXML[OPML](bytes.TrimSpace(bytes.TrimPrefix(body, utf8BOM)))
+	if err != nil {
+		return importSet{}, err
+	}
+
+	set := importSet{CategoryParents: make(map[string]string), Skipped: make([]SkippedFeed, 0)}
+	seenURLs := make(map[string]bool)
+	seenCategories := make(map[string]bool)
+	collectOPMLOutlines(document.Body.Outlines, "", &set, seenURLs, seenCategories)
+	return set, nil
+}
+
+func collectOPMLOutlines(outlines []OPMLOutline, categoryName string, set *importSet, seenURLs, seenCategories map[string]bool) {
+	for _, outline := range outlines {
+		name := firstNonEmpty(outline.Text, outline.Title)
+		feedURL := strings.TrimSpace(outline.XMLURL)
+
+		if feedURL == "" {
+			if strings.EqualFold(outline.Type, "rss") || strings.EqualFold(outline.Type, "atom") {
+				set.Skipped = append(set.Skipped, SkippedFeed{Name: name, Reason: "missing xmlUrl"})
+				continue
+			}
+			childCategory := categoryName
+			if name != "" {
+				childCategory = name
+				if !seenCategories[name] {
+					seenCategories[name] = true
+					set.Categories = append(set.Categories, name)
+					if categoryName != "" && categoryName != name {
+						set.CategoryParents[name] = categoryName
+					}
+				}
+			}
+			collectOPMLOutlines(outline.Outlines, childCategory, set, seenURLs, seenCategories)
+			continue
+		}
+
+		if !isValidFeedURL(feedURL) {
+			set.Skipped = append(set.Skipped, SkippedFeed{Name: name, URL: feedURL, Reason: "invalid xmlUrl"})
+			continue
+		}
+		if seenURLs[feedURL] {
+			set.Skipped = append(set.Skipped, SkippedFeed{Name: name, URL: feedURL, Reason: "duplicate xmlUrl"})
+			continue
+		}
+		seenURLs[feedURL] = true
+
+		if name == "" {
+			name = feedURL
+		}
+
+		var fetchInterval *int
+		if parsed, err := strconv.Atoi(strings.TrimSpace(outline.FetchInterval)); err == nil {
+			fetchInterval = validFetchInterval(&parsed)
+		}
+
+		set.Feeds = append(set.Feeds, importFeed{
+			Name:          name,
+			URL:           feedURL,
+			SiteURL:       strings.TrimSpace(outline.HTMLURL),
+			CategoryName:  categoryName,
+			FetchInterval: fetchInterval,
+		})
+
+		if len(outline.Outlines) > 0 {
+			collectOPMLOutlines(outline.Outlines, categoryName, set, seenURLs, seenCategories)
+		}
+	}
+}
+
+func (s *Server) handleExportOPML(c *gin.Context) {
+	categoryRows, err := s.db.Query(`SELECT id, name, parent_id FROM categories ORDER BY name ASC`)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer categoryRows.Close()
+
+	categoryNames := make(map[int64]string)
+	childCategories := make(map[int64][]int64)
+	rootCategories := make([]int64, 0)
+	for categoryRows.Next() {
+		var categoryID int64
+		var name string
+		var parentID sql.NullInt64
+		if err := categoryRows.Scan(&categoryID, &name, &parentID); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		categoryNames[categoryID] = name
+		if parentID.Valid {
+			childCategories[parentID.Int64] = append(childCategories[parentID.Int64], categoryID)
+		} else {
+			rootCategories = append(rootCategories, categoryID)
+		}
+	}
+
+	feedRows, err := s.db.Query(`
+		SELECT f.name, f.url, f.site_url, f.fetch_interval_minutes, f.category_id
+		FROM feeds f
+		ORDER BY f.name ASC
+	`)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer feedRows.Close()
+
+	categoryFeeds := make(map[int64][]OPMLOutline)
+	uncategorized := make([]OPMLOutline, 0)
+	for feedRows.Next() {
+		var name, feedURL string
+		var siteURL sql.NullString
+		var fetchInterval int
+		var categoryID sql.NullInt64
+		if err := feedRows.Scan(&name, &feedURL, &siteURL, &fetchInterval, &categoryID); err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		outline := OPMLOutline{
+			Text:          name,
+			Title:         name,
+			Type:          "rss",
+			XMLURL:        feedURL,
+			HTMLURL:       siteURL.String,
+			FetchInterval: strconv.Itoa(fetchInterval),
+		}
+		if _, ok := categoryNames[categoryID.Int64]; ok && categoryID.Valid {
+			categoryFeeds[categoryID.Int64] = append(categoryFeeds[categoryID.Int64], outline)
+		} else {
+			uncategorized = append(uncategorized, outline)
+		}
+	}
+
+	visited := make(map[int64]bool)
+	var categoryOutline func(categoryID int64) OPMLOutline
+	categoryOutline = func(categoryID int64) OPMLOutline {
+		visited[categoryID] = true
+		outline := OPMLOutline{Text: categoryNames[categoryID], Title: categoryNames[categoryID]}
+		for _, childID := range childCategories[categoryID] {
+			if !visited[childID] {
+				outline.Outlines = append(outline.Outlines, categoryOutline(childID))
+			}
+		}
+		outline.Outlines = append(outline.Outlines, categoryFeeds[categoryID]...)
+		return outline
+	}
+
+	document := OPML{
+		Version: "2.0",
+		Head: OPMLHead{
+			Title:       "To-Reads subscriptions",
+			DateCreated: time.Now().UTC().Format(time.RFC1123Z),
+		},
+	}
+	for _, categoryID := range rootCategories {
+		document.Body.Outlines = append(document.Body.Outlines, categoryOutline(categoryID))
+	}
+	document.Body.Outlines = append(document.Body.Outlines, uncategorized...)
+
+	encoded, err := xml.MarshalIndent(document, "", "  ")
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="to-reads.opml"`)
+	c.Data(http.StatusOK, "text/x-opml; charset=utf-8", append([]byte(xml.Header), encoded...))
+}
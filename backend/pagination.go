@@ -0,0 +1,184 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+type itemSortKey struct {
+	Expr     string
+	Cast     string
+	Desc     bool
+	Nullable bool
+}
+
+type itemOrder struct {
+	Name string
+	Keys []itemSortKey
+}
+
+type itemCursor struct {
+	Sort     string    `json:"s"`
+	Values   []*string `json:"v"`
+	Backward bool      `json:"b,omitempty"`
+}
+
+var (
+	publishedSortKey = itemSortKey{Expr: "i.published_at", Cast: "timestamptz", Desc: true, Nullable: true}
+	createdSortKey   = itemSortKey{Expr: "i.created_at", Cast: "timestamptz", Desc: true}
+	idSortKey        = itemSortKey{Expr: "i.id", Cast: "bigint", Desc: true}
+)
+
+var itemSorts = map[string]bool{"newest": true, "oldest": true, "feed": true, "fetched": true, "relevance": true}
+
+func newestItemOrder() itemOrder {
+	return itemOrder{Name: "newest", Keys: []itemSortKey{publishedSortKey, createdSortKey, idSortKey}}
+}
+
+func oldestItemOrder() itemOrder {
+	return itemOrder{Name: "oldest", Keys: []itemSortKey{publishedSortKey.ascending(), createdSortKey.ascending(), idSortKey.ascending()}}
+}
+
+func feedItemOrder() itemOrder {
+	feedName := itemSortKey{Expr: "f.name", Cast: "text"}
+	feedID := itemSortKey{Expr: "i.feed_id", Cast: "bigint"}
+	return itemOrder{Name: "feed", Keys: []itemSortKey{feedName, feedID, publishedSortKey, createdSortKey, idSortKey}}
+}
+
+func fetchedItemOrder() itemOrder {
+	return itemOrder{Name: "fetched", Keys: []itemSortKey{createdSortKey, idSortKey}}
+}
+
+func (k itemSortKey) ascending() itemSortKey {
+	k.Desc = false
+	return k
+}
+
+func relevanceItemOrder(rankExpr string) itemOrder {
+	rank := itemSortKey{Expr: rankExpr, Cast: "real", Desc: true}
+	return itemOrder{Name: "relevance", Keys: []itemSortKey{rank, publishedSortKey, createdSortKey, idSortKey}}
+}
+
+func (o itemOrder) clause(backward bool) string {
+	parts := make([]string, 0, len(o.Keys))
+	for _, key := range o.Keys {
+		desc := key.Desc != backward
+		part := key.Expr + " ASC"
+		if desc {
+			part = key.Expr + " DESC"
+		}
+		if key.Nullable {
+			if backward {
+				part += " NULLS FIRST"
+			} else {
+				part += " NULLS LAST"
+			}
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (o itemOrder) selectList() string {
+	parts := make([]string, 0, len(o.Keys))
+	for _, key := range o.Keys {
+		parts = append(parts, "("+key.Expr+")::text")
+	}
+	return strings.Join(parts, ", ")
+}
+
+func (o itemOrder) scanTargets() ([]interface{}, []sql.NullString) {
+	values := make([]sql.NullString, len(o.Keys))
+	targets := make([]interface{}, len(o.Keys))
+	for index := range values {
+		targets[index] = &values[index]
+	}
+	return targets, values
+}
+
+func encodeItemCursor(order itemOrder, values []sql.NullString, backward bool) *string {
+	cursor := itemCursor{Sort: order.Name, Values: make([]*string, len(values)), Backward: backward}
+	for index, value := range values {
+		if value.Valid {
+			text := value.String
+			cursor.Values[index] = &text
+		}
+	}
+	encoded, err := json.Marshal(cursor)
+	if err != nil {
+		return nil
+	}
+	token := base64.RawURLEncoding.EncodeToString(encoded)
+	return &token
+}
+
+func decodeItemCursor(token string, order itemOrder) (itemCursor, error) {
+	var cursor itemCursor
+	decoded, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return cursor, errInvalidCursor
+	}
+	if err := json.Unmarshal(decoded, &cursor); err != nil || cursor.Sort != order.Name || len(cursor.Values) != len(order.Keys) {
+		return itemCursor{}, errInvalidCursor
+	}
+	for index, key := range order.Keys {
+		if cursor.Values[index] == nil && !key.Nullable {
+			return itemCursor{}, errInvalidCursor
+		}
+	}
+	return cursor, nil
+}
+
+func (o itemOrder) keysetCondition(cursor itemCursor, argIndex int) (string, []interface{}, int) {
+	args := []interface{}{}
+	params := make([]string, len(o.Keys))
+	for index, value := range cursor.Values {
+		if value == nil {
+			continue
+		}
+		params[index] = "$" + strconv.Itoa(argIndex) + "::" + o.Keys[index].Cast
+		args = append(args, *value)
+		argIndex++
+	}
+
+	alternatives := make([]string, 0, len(o.Keys))
+	equalities := make([]string, 0, len(o.Keys))
+	for index, key := range o.Keys {
+		if beyond := keyBeyond(key, params[index], cursor.Backward); beyond != "" {
+			alternatives = append(alternatives, "("+strings.Join(append(append([]string{}, equalities...), beyond), " AND ")+")")
+		}
+		if params[index] == "" {
+			equalities = append(equalities, key.Expr+" IS NULL")
+		} else {
+			equalities = append(equalities, key.Expr+" = "+params[index])
+		}
+	}
+	if len(alternatives) == 0 {
+		return "FALSE", args, argIndex
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")", args, argIndex
+}
+
+func keyBeyond(key itemSortKey, param string, backward bool) string {
+	if param == "" {
+		if backward {
+			return key.Expr + " IS NOT NULL"
+		}
+		return ""
+	}
+	operator := "<"
+	if key.Desc == backward {
+		operator = ">"
+	}
+	condition := key.Expr + " " + operator + " " + param
+	if key.Nullable && !backward {
+		condition = "(" + condition + " OR " + key.Expr + " IS NULL)"
+	}
+	return condition
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+func TestItemCursorRoundTrip(t *testing.T) {
+	order := newestItemOrder()
+	values := []sql.NullString{
+		{},
+		{String: "2025-05-01 12:00:00+00", Valid: true},
+		{String: "42", Valid: true},
+	}
+
+	for _, backward := range []bool{false, true} {
+		token := encodeItemCursor(order, values, backward)
+		if token == nil {
+			t.Fatal("encodeItemCursor() = nil")
+		}
+		cursor, err := decodeItemCursor(*token, order)
+		if err != nil {
+			t.Fatalf("decodeItemCursor() error = %v", err)
+		}
+		want := itemCursor{
+			Sort:     "newest",
+			Values:   []*string{nil, stringPointer("2025-05-01 12:00:00+00"), stringPointer("42")},
+			Backward: backward,
+		}
+		if !reflect.DeepEqual(cursor, want) {
+			t.Fatalf("decodeItemCursor() = %+v, want %+v", cursor, want)
+		}
+	}
+}
+
+func TestDecodeItemCursorRejects(t *testing.T) {
+	encode := func(raw string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(raw))
+	}
+	valid := *encodeItemCursor(fetchedItemOrder(), []sql.NullString{
+		{String: "2025-05-01 12:00:00+00", Valid: true},
+		{String: "42", Valid: true},
+	}, false)
+
+	tests := []struct {
+		name  string
+		token string
+		order itemOrder
+	}{
+		{"not base64", "!!not-base64!!", newestItemOrder()},
+		{"not json", encode("not json"), newestItemOrder()},
+		{"tampered payload", valid[:len(valid)-3] + "AAA", fetchedItemOrder()},
+		{"sort mismatch", valid, newestItemOrder()},
+		{"too few values", encode(`{"s":"newest","v":["2025-05-01"]}`), newestItemOrder()},
+		{"too many values", encode(`{"s":"fetched","v":["2025-05-01","1","2"]}`), fetchedItemOrder()},
+		{"null non-nullable key", encode(`{"s":"fetched","v":["2025-05-01",null]}`), fetchedItemOrder()},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor, err := decodeItemCursor(test.token, test.order)
+			if !errors.Is(err, errInvalidCursor) {
+				t.Fatalf("decodeItemCursor() = %+v, %v, want errInvalidCursor", cursor, err)
+			}
+		})
+	}
+}
+
+func TestKeysetCondition(t *testing.T) {
+	tests := []struct {
+		name      string
+		order     itemOrder
+		values    []*string
+		backward  bool
+		argIndex  int
+		want      string
+		wantArgs  []interface{}
+		wantIndex int
+	}{
+		{
+			name:     "newest forward",
+			order:    newestItemOrder(),
+			values:   []*string{stringPointer("p"), stringPointer("c"), stringPointer("7")},
+			argIndex: 3,
+			want: "(((i.published_at < $3::timestamptz OR i.published_at IS NULL))" +
+				" OR (i.published_at = $3::timestamptz AND i.created_at < $4::timestamptz)" +
+				" OR (i.published_at = $3::timestamptz AND i.created_at = $4::timestamptz AND i.id < $5::bigint))",
+			wantArgs:  []interface{}{"p", "c", "7"},
+			wantIndex: 6,
+		},
+		{
+			name:     "newest forward from undated item",
+			order:    newestItemOrder(),
+			values:   []*string{nil, stringPointer("c"), stringPointer("7")},
+			argIndex: 1,
+			want: "((i.published_at IS NULL AND i.created_at < $1::timestamptz)" +
+				" OR (i.published_at IS NULL AND i.created_at = $1::timestamptz AND i.id < $2::bigint))",
+			wantArgs:  []interface{}{"c", "7"},
+			wantIndex: 3,
+		},
+		{
+			name:     "newest backward",
+			order:    newestItemOrder(),
+			values:   []*string{stringPointer("p"), stringPointer("c"), stringPointer("7")},
+			backward: true,
+			argIndex: 1,
+			want: "((i.published_at > $1::timestamptz)" +
+				" OR (i.published_at = $1::timestamptz AND i.created_at > $2::timestamptz)" +
+				" OR (i.published_at = $1::timestamptz AND i.created_at = $2::timestamptz AND i.id > $3::bigint))",
+			wantArgs:  []interface{}{"p", "c", "7"},
+			wantIndex: 4,
+		},
+		{
+			name:     "newest backward from undated item",
+			order:    newestItemOrder(),
+			values:   []*string{nil, stringPointer("c"), stringPointer("7")},
+			backward: true,
+			argIndex: 1,
+			want: "((i.published_at IS NOT NULL)" +
+				" OR (i.published_at IS NULL AND i.created_at > $1::timestamptz)" +
+				" OR (i.published_at IS NULL AND i.created_at = $1::timestamptz AND i.id > $2::bigint))",
+			wantArgs:  []interface{}{"c", "7"},
+			wantIndex: 3,
+		},
+		{
+			name:     "oldest forward",
+			order:    oldestItemOrder(),
+			values:   []*string{stringPointer("p"), stringPointer("c"), stringPointer("7")},
+			argIndex: 1,
+			want: "(((i.published_at > $1::timestamptz OR i.published_at IS NULL))" +
+				" OR (i.published_at = $1::timestamptz AND i.created_at > $2::timestamptz)" +
+				" OR (i.published_at = $1::timestamptz AND i.created_at = $2::timestamptz AND i.id > $3::bigint))",
+			wantArgs:  []interface{}{"p", "c", "7"},
+			wantIndex: 4,
+		},
+		{
+			name:     "feed forward",
+			order:    feedItemOrder(),
+			values:   []*string{stringPointer("Blog"), stringPointer("2"), stringPointer("p"), stringPointer("c"), stringPointer("7")},
+			argIndex: 1,
+			want: "((f.name > $1::text)" +
+				" OR (f.name = $1::text AND i.feed_id > $2::bigint)" +
+				" OR (f.name = $1::text AND i.feed_id = $2::bigint AND (i.published_at < $3::timestamptz OR i.published_at IS NULL))" +
+				" OR (f.name = $1::text AND i.feed_id = $2::bigint AND i.published_at = $3::timestamptz AND i.created_at < $4::timestamptz)" +
+				" OR (f.name = $1::text AND i.feed_id = $2::bigint AND i.published_at = $3::timestamptz AND i.created_at = $4::timestamptz AND i.id < $5::bigint))",
+			wantArgs:  []interface{}{"Blog", "2", "p", "c", "7"},
+			wantIndex: 6,
+		},
+		{
+			name:      "fetched forward",
+			order:     fetchedItemOrder(),
+			values:    []*string{stringPointer("c"), stringPointer("7")},
+			argIndex:  2,
+			want:      "((i.created_at < $2::timestamptz) OR (i.created_at = $2::timestamptz AND i.id < $3::bigint))",
+			wantArgs:  []interface{}{"c", "7"},
+			wantIndex: 4,
+		},
+		{
+			name:      "fetched backward",
+			order:     fetchedItemOrder(),
+			values:    []*string{stringPointer("c"), stringPointer("7")},
+			backward:  true,
+			argIndex:  1,
+			want:      "((i.created_at > $1::timestamptz) OR (i.created_at = $1::timestamptz AND i.id > $2::bigint))",
+			wantArgs:  []interface{}{"c", "7"},
+			wantIndex: 3,
+		},
+		{
+			name:     "relevance forward",
+			order:    relevanceItemOrder("rank"),
+			values:   []*string{stringPointer("0.5"), stringPointer("p"), stringPointer("c"), stringPointer("7")},
+			argIndex: 2,
+			want: "((rank < $2::real)" +
+				" OR (rank = $2::real AND (i.published_at < $3::timestamptz OR i.published_at IS NULL))" +
+				" OR (rank = $2::real AND i.published_at = $3::timestamptz AND i.created_at < $4::timestamptz)" +
+				" OR (rank = $2::real AND i.published_at = $3::timestamptz AND i.created_at = $4::timestamptz AND i.id < $5::bigint))",
+			wantArgs:  []interface{}{"0.5", "p", "c", "7"},
+			wantIndex: 6,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cursor := itemCursor{Sort: test.order.Name, Values: test.values, Backward: test.backward}
+			condition, args, nextIndex := test.order.keysetCondition(cursor, test.argIndex)
+			if condition != test.want {
+				t.Errorf("condition =\n%s\nwant\n%s", condition, test.want)
+			}
+			if !reflect.DeepEqual(args, test.wantArgs) {
+				t.Errorf("args = %v, want %v", args, test.wantArgs)
+			}
+			if nextIndex != test.wantIndex {
+				t.Errorf("next index = %d, want %d", nextIndex, test.wantIndex)
+			}
+		})
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultPreviewLimit = 10
+	maxPreviewLimit     = 50
+)
+
+var xmlEncodingPattern = regexp.MustCompile(`^<\?xml[^>]*encoding=["']([^"']+)["']`)
+
+type previewFeedRequest struct {
+	URL   string `json:"url"`
+	Limit int    `json:"limit"`
+}
+
+type FeedPreview struct {
+	URL         string              `json:"url"`
+	FinalURL    string              `json:"final_url"`
+	Format      string              `json:"format"`
+	Title       string              `json:"title"`
+	SiteURL     string              `json:"site_url"`
+	Description string              `json:"description"`
+	ItemCount   int                 `json:"item_count"`
+	Items       []PreviewItem       `json:"items"`
+	Warnings    []ParseWarning      `json:"warnings"`
+	Diagnostics []PreviewDiagnostic `json:"diagnostics"`
+}
+
+type PreviewItem struct {
+	Title       string      `json:"title"`
+	Link        string      `json:"link"`
+	Summary     string      `json:"summary"`
+	GUID        string      `json:"guid"`
+	PublishedAt *time.Time  `json:"published_at"`
+	Enclosures  []Enclosure `json:"enclosures"`
+}
+
+type PreviewDiagnostic struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Items   []int  `json:"items,omitempty"`
+}
+
+func (s *Server) handlePreviewFeed(c *gin.Context) {
+	var req previewFeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	feedURL := strings.TrimSpace(req.URL)
+	if feedURL == "" {
+		respondErrorMessage(c, http.StatusBadRequest, "url is required")
+		return
+	}
+	if parsedURL, err := url.Parse(feedURL); err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid url")
+		return
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultPreviewLimit
+	}
+	if limit > maxPreviewLimit {
+		limit = maxPreviewLimit
+	}
+
+	document, err := downloadFeed(c.Request.Context(), feedURL)
+	if err != nil {
+		respondError(c, http.StatusBadGateway, err)
+		return
+	}
+
+	feed, err := parseFeed(document.Body, document.ContentType, document.FinalURL)
+	if err != nil {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	items := make([]PreviewItem, 0, limit)
+	for _, item := range feed.Items {
+		if len(items) == limit {
+			break
+		}
+		items = append(items, PreviewItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			Summary:     item.Summary,
+			GUID:        item.GUID,
+			PublishedAt: item.Published,
+			Enclosures:  item.Enclosures,
+		})
+	}
+
+	warnings := feed.Warnings
+	if warnings == nil {
+		warnings = make([]ParseWarning, 0)
+	}
+
+	respondSuccess(c, http.StatusOK, FeedPreview{
+		URL:         feedURL,
+		FinalURL:    document.FinalURL,
+		Format:      feed.Format,
+		Title:       feed.Title,
+		SiteURL:     feed.SiteURL,
+		Description: feed.Description,
+		ItemCount:   len(feed.Items),
+		Items:       items,
+		Warnings:    warnings,
+		Diagnostics: diagnoseFeed(document, feed),
+	})
+}
+
+func diagnoseFeed(document feedDocument, feed ParsedFeed) []PreviewDiagnostic {
+	diagnostics := encodingDiagnostics(document)
+
+	if len(feed.Items) == 0 {
+		diagnostics = append(diagnostics, PreviewDiagnostic{Code: "no_items", Message: "feed contains no items"})
+	}
+
+	var missingGUIDs, invalidDates, missingDates, relativeLinks, duplicateIDs []int
+	firstIndexByGUID := make(map[string]int)
+	for index, item := range feed.Items {
+		if !item.HasGUID {
+			missingGUIDs = append(missingGUIDs, index)
+		}
+		if item.RawPublished == "" {
+			missingDates = append(missingDates, index)
+		} else if item.Published == nil {
+			invalidDates = append(invalidDates, index)
+		}
+		if item.RawLink != "" {
+			if linkURL, err := url.Parse(item.RawLink); err == nil && !linkURL.IsAbs() {
+				relativeLinks = append(relativeLinks, index)
+			}
+		}
+		if item.GUID != "" {
+			if _, ok := firstIndexByGUID[item.GUID]; ok {
+				duplicateIDs = append(duplicateIDs, index)
+			} else {
+				firstIndexByGUID[item.GUID] = index
+			}
+		}
+	}
+
+	diagnostics = appendItemDiagnostic(diagnostics, "missing_guid", "items without a guid/id; the link is used for deduplication", missingGUIDs)
+	diagnostics = appendItemDiagnostic(diagnostics, "invalid_date", "items with a date that could not be parsed", invalidDates)
+	diagnostics = appendItemDiagnostic(diagnostics, "missing_date", "items without a date; the first fetch time will be used", missingDates)
+	diagnostics = appendItemDiagnostic(diagnostics, "duplicate_id", "items sharing an id with an earlier item; only the first will be stored", duplicateIDs)
+	diagnostics = appendItemDiagnostic(diagnostics, "relative_link", "items with relative links that were resolved against the feed URL", relativeLinks)
+	return diagnostics
+}
+
+func appendItemDiagnostic(diagnostics []PreviewDiagnostic, code, message string, items []int) []PreviewDiagnostic {
+	if len(items) == 0 {
+		return diagnostics
+	}
+	return append(diagnostics, PreviewDiagnostic{
+		Code:    code,
+		Message: strconv.Itoa(len(items)) + " " + message,
+		Items:   items,
+	})
+}
+
+func encodingDiagnostics(document feedDocument) []PreviewDiagnostic {
+	diagnostics := make([]PreviewDiagnostic, 0)
+	body := bytes.TrimSpace(document.Body)
+
+	headerCharset := ""
+	if _, params, err := mime.ParseMediaType(document.ContentType); err == nil {
+		headerCharset = strings.ToLower(strings.TrimSpace(params["charset"]))
+	}
+	declaredCharset := ""
+	if match := xmlEncodingPattern.FindSubmatch(bytes.TrimPrefix(body, utf8BOM)); match != nil {
+		declaredCharset = strings.ToLower(strings.TrimSpace(string(match[1])))
+	}
+
+	if bytes.HasPrefix(body, utf8BOM) {
+		diagnostics = append(diagnostics, PreviewDiagnostic{Code: "byte_order_mark", Message: "document starts with a UTF-8 byte order mark"})
+	}
+	if headerCharset != "" && declaredCharset != "" && normalizeCharset(headerCharset) != normalizeCharset(declaredCharset) {
+		diagnostics = append(diagnostics, PreviewDiagnostic{
+			Code:    "charset_mismatch",
+			Message: "Content-Type charset " + headerCharset + " differs from declared encoding " + declaredCharset,
+		})
+	}
+	if (declaredCharset == "" || normalizeCharset(declaredCharset) == "utf8") && !utf8.Valid(body) {
+		diagnostics = append(diagnostics, PreviewDiagnostic{Code: "invalid_utf8", Message: "document contains bytes that are not valid UTF-8"})
+	}
+	if !bytes.HasPrefix(body, []byte("{")) && len(sanitizeXML(body)) != len(bytes.TrimPrefix(body, utf8BOM)) {
+		diagnostics = append(diagnostics, PreviewDiagnostic{Code: "invalid_characters", Message: "document contains characters that are not allowed in XML"})
+	}
+	return diagnostics
+}
+
+func normalizeCharset(value string) string {
+	return strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(value))
+}
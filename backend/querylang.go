@@ -0,0 +1,395 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type searchQueryError struct {
+	Position int    `json:"position"`
+	Message  string `json:"message"`
+}
+
+func (e searchQueryError) Error() string {
+	return fmt.Sprintf("invalid query at position %d: %s", e.Position, e.Message)
+}
+
+type searchTokenKind int
+
+const (
+	searchTokenTerm searchTokenKind = iota
+	searchTokenOr
+	searchTokenNot
+	searchTokenOpen
+	searchTokenClose
+)
+
+var searchFields = map[string]bool{
+	"feed":     true,
+	"category": true,
+	"is":       true,
+	"author":   true,
+	"tag":      true,
+	"before":   true,
+	"after":    true,
+}
+
+type searchToken struct {
+	Kind     searchTokenKind
+	Position int
+	Field    string
+	Value    string
+	Quoted   bool
+	Date     time.Time
+}
+
+type searchNode struct {
+	Op       string
+	Children []*searchNode
+	Token    searchToken
+}
+
+type searchQuery struct {
+	Root *searchNode
+}
+
+func parseSearchQuery(input string) (searchQuery, error) {
+	tokens, err := tokenizeSearchQuery(input)
+	if err != nil {
+		return searchQuery{}, err
+	}
+	if len(tokens) == 0 {
+		return searchQuery{}, nil
+	}
+	parser := searchParser{tokens: tokens, end: len([]rune(input)) + 1}
+	root, err := parser.parseOr()
+	if err != nil {
+		return searchQuery{}, err
+	}
+	if token, ok := parser.peek(); ok {
+		return searchQuery{}, searchQueryError{Position: token.Position, Message: "unexpected closing parenthesis"}
+	}
+	return searchQuery{Root: root}, nil
+}
+
+func tokenizeSearchQuery(input string) ([]searchToken, error) {
+	runes := []rune(input)
+	tokens := make([]searchToken, 0)
+	isBoundary := func(r rune) bool {
+		return unicode.IsSpace(r) || r == '(' || r == ')'
+	}
+	readQuoted := func(start int) (string, int, error) {
+		for end := start + 1; end < len(runes); end++ {
+			if runes[end] == '"' {
+				return string(runes[start+1 : end]), end + 1, nil
+			}
+		}
+		return "", 0, searchQueryError{Position: start + 1, Message: "unterminated quoted phrase"}
+	}
+
+	for index := 0; index < len(runes); {
+		r := runes[index]
+		switch {
+		case unicode.IsSpace(r):
+			index++
+		case r == '(':
+			tokens = append(tokens, searchToken{Kind: searchTokenOpen, Position: index + 1})
+			index++
+		case r == ')':
+			tokens = append(tokens, searchToken{Kind: searchTokenClose, Position: index + 1})
+			index++
+		case r == '-' && (index+1 == len(runes) || unicode.IsSpace(runes[index+1]) || runes[index+1] == ')'):
+			index++
+		case r == '-':
+			tokens = append(tokens, searchToken{Kind: searchTokenNot, Position: index + 1})
+			index++
+		case r == '"':
+			value, next, err := readQuoted(index)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, searchToken{Kind: searchTokenTerm, Position: index + 1, Value: value, Quoted: true})
+			index = next
+		default:
+			start := index
+			for index < len(runes) && !isBoundary(runes[index]) && runes[index] != ':' {
+				index++
+			}
+			word := string(runes[start:index])
+			field := strings.ToLower(word)
+			if index < len(runes) && runes[index] == ':' && searchFields[field] {
+				index++
+				token := searchToken{Kind: searchTokenTerm, Position: start + 1, Field: field}
+				if index < len(runes) && runes[index] == '"' {
+					value, next, err := readQuoted(index)
+					if err != nil {
+						return nil, err
+					}
+					token.Value = value
+					token.Quoted = true
+					index = next
+				} else {
+					valueStart := index
+					for index < len(runes) && !isBoundary(runes[index]) {
+						index++
+					}
+					token.Value = string(runes[valueStart:index])
+				}
+				if strings.TrimSpace(token.Value) == "" {
+					return nil, searchQueryError{Position: start + 1, Message: "missing value for " + field + ":"}
+				}
+				tokens = append(tokens, token)
+				continue
+			}
+			for index < len(runes) && !isBoundary(runes[index]) {
+				index++
+			}
+			word = string(runes[start:index])
+			if word == "OR" {
+				tokens = append(tokens, searchToken{Kind: searchTokenOr, Position: start + 1})
+				continue
+			}
+			tokens = append(tokens, searchToken{Kind: searchTokenTerm, Position: start + 1, Value: word})
+		}
+	}
+	return tokens, nil
+}
+
+type searchParser struct {
+	tokens []searchToken
+	index  int
+	end    int
+}
+
+func (p *searchParser) peek() (searchToken, bool) {
+	if p.index >= len(p.tokens) {
+		return searchToken{}, false
+	}
+	return p.tokens[p.index], true
+}
+
+func (p *searchParser) position() int {
+	if token, ok := p.peek(); ok {
+		return token.Position
+	}
+	return p.end
+}
+
+func (p *searchParser) parseOr() (*searchNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*searchNode{left}
+	for {
+		token, ok := p.peek()
+		if !ok || token.Kind != searchTokenOr {
+			break
+		}
+		p.index++
+		if next, ok := p.peek(); !ok || next.Kind == searchTokenOr || next.Kind == searchTokenClose {
+			return nil, searchQueryError{Position: token.Position, Message: "OR must be followed by a search term"}
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &searchNode{Op: "or", Children: children}, nil
+}
+
+func (p *searchParser) parseAnd() (*searchNode, error) {
+	children := make([]*searchNode, 0)
+	for {
+		token, ok := p.peek()
+		if !ok || token.Kind == searchTokenClose {
+			break
+		}
+		if token.Kind == searchTokenOr {
+			if len(children) == 0 {
+				return nil, searchQueryError{Position: token.Position, Message: "OR must be preceded by a search term"}
+			}
+			break
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+	}
+	if len(children) == 0 {
+		return nil, searchQueryError{Position: p.position(), Message: "expected a search term"}
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return &searchNode{Op: "and", Children: children}, nil
+}
+
+func (p *searchParser) parseUnary() (*searchNode, error) {
+	token, _ := p.peek()
+	p.index++
+	switch token.Kind {
+	case searchTokenNot:
+		if next, ok := p.peek(); !ok || next.Kind == searchTokenOr || next.Kind == searchTokenClose {
+			return nil, searchQueryError{Position: token.Position, Message: "- must be followed by a search term"}
+		}
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &searchNode{Op: "not", Children: []*searchNode{child}}, nil
+	case searchTokenOpen:
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if next, ok := p.peek(); !ok || next.Kind != searchTokenClose {
+			return nil, searchQueryError{Position: token.Position, Message: "unclosed parenthesis"}
+		}
+		p.index++
+		return inner, nil
+	case searchTokenTerm:
+		if err := validateSearchTerm(&token); err != nil {
+			return nil, err
+		}
+		return &searchNode{Op: "term", Token: token}, nil
+	}
+	return nil, searchQueryError{Position: token.Position, Message: "unexpected token"}
+}
+
+func validateSearchTerm(token *searchToken) error {
+	switch token.Field {
+	case "is":
+		token.Value = strings.ToLower(token.Value)
+		switch token.Value {
+		case "unread", "read", "starred", "favorite", "later":
+			return nil
+		}
+		return searchQueryError{Position: token.Position, Message: fmt.Sprintf("unknown state %q, expected unread, read, starred or later", token.Value)}
+	case "before", "after":
+		for _, layout := range []string{"2006-01-02", time.RFC3339} {
+			if parsed, err := time.Parse(layout, token.Value); err == nil {
+				token.Date = parsed
+				return nil
+			}
+		}
+		return searchQueryError{Position: token.Position, Message: fmt.Sprintf("invalid date %q, expected YYYY-MM-DD", token.Value)}
+	}
+	return nil
+}
+
+func (q searchQuery) condition(argIndex int) (string, []interface{}, int) {
+	if q.Root == nil {
+		return "", nil, argIndex
+	}
+	args := []interface{}{}
+	condition := compileSearchNode(q.Root, false, &args, &argIndex)
+	return condition, args, argIndex
+}
+
+func compileSearchNode(node *searchNode, negated bool, args *[]interface{}, argIndex *int) string {
+	param := func(value interface{}) string {
+		placeholder := "$" + strconv.Itoa(*argIndex)
+		*args = append(*args, value)
+		*argIndex++
+		return placeholder
+	}
+
+	switch node.Op {
+	case "and", "or":
+		parts := make([]string, 0, len(node.Children))
+		for _, child := range node.Children {
+			parts = append(parts, compileSearchNode(child, negated, args, argIndex))
+		}
+		return "(" + strings.Join(parts, " "+strings.ToUpper(node.Op)+" ") + ")"
+	case "not":
+		return "NOT COALESCE(" + compileSearchNode(node.Children[0], !negated, args, argIndex) + ", FALSE)"
+	}
+
+	token := node.Token
+	_, numericErr := parseIDParam(token.Value)
+	switch token.Field {
+	case "feed":
+		if numericErr == nil {
+			return "i.feed_id = " + param(token.Value) + "::bigint"
+		}
+		return "f.name ILIKE " + param(namePattern(token.Value))
+	case "category":
+		if numericErr == nil {
+			return categoryTreeCondition("SELECT " + param(token.Value) + "::bigint")
+		}
+		return categoryTreeCondition("SELECT id FROM categories WHERE name ILIKE " + param(namePattern(token.Value)))
+	case "author":
+		return "i.author ILIKE " + param(likePattern(token.Value))
+	case "tag":
+		return "EXISTS (SELECT 1 FROM item_tags it JOIN tags t ON t.id = it.tag_id WHERE it.item_id = i.id AND lower(t.name) = lower(" + param(token.Value) + "))"
+	case "before":
+		return "i.published_at < " + param(token.Date)
+	case "after":
+		return "i.published_at >= " + param(token.Date)
+	case "is":
+		switch token.Value {
+		case "unread":
+			return "i.is_read = FALSE"
+		case "read":
+			return "i.is_read = TRUE"
+		case "starred", "favorite":
+			return "i.is_favorite = TRUE"
+		default:
+			return "EXISTS (SELECT 1 FROM read_later rl WHERE rl.item_id = i.id)"
+		}
+	}
+	function := "plainto_tsquery"
+	if token.Quoted {
+		function = "phraseto_tsquery"
+	}
+	tsQuery := function + "(f.search_config, " + param(token.Value) + ")"
+	if negated {
+		return "(numnode(" + tsQuery + ") > 0 AND i.search_vector @@ " + tsQuery + ")"
+	}
+	return "(numnode(" + tsQuery + ") = 0 OR i.search_vector @@ " + tsQuery + ")"
+}
+
+func (q searchQuery) rankText() string {
+	terms := make([]string, 0)
+	var collect func(node *searchNode)
+	collect = func(node *searchNode) {
+		if node == nil || node.Op == "not" {
+			return
+		}
+		if node.Op == "term" {
+			if node.Token.Field != "" {
+				return
+			}
+			value := strings.ReplaceAll(node.Token.Value, `"`, "")
+			if node.Token.Quoted {
+				value = `"` + value + `"`
+			}
+			terms = append(terms, value)
+			return
+		}
+		for _, child := range node.Children {
+			collect(child)
+		}
+	}
+	collect(q.Root)
+	return strings.Join(terms, " or ")
+}
+
+func namePattern(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+	return strings.ReplaceAll(escaped, "*", "%")
+}
+
+func likePattern(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+	return "%" + escaped + "%"
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func renderSearchNode(node *searchNode) string {
+	if node == nil {
+		return ""
+	}
+	if node.Op == "term" {
+		value := node.Token.Value
+		if node.Token.Quoted {
+			value = `"` + value + `"`
+		}
+		if node.Token.Field != "" {
+			return node.Token.Field + ":" + value
+		}
+		return value
+	}
+	parts := []string{node.Op}
+	for _, child := range node.Children {
+		parts = append(parts, renderSearchNode(child))
+	}
+	return "(" + strings.Join(parts, " ") + ")"
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"golang", "golang"},
+		{"a b c", "(and a b c)"},
+		{"a b OR c", "(or (and a b) c)"},
+		{"a OR b c", "(or a (and b c))"},
+		{"a OR b OR c", "(or a b c)"},
+		{"a (b OR c)", "(and a (or b c))"},
+		{"(a OR b) (c OR d)", "(and (or a b) (or c d))"},
+		{"a or b", "(and a or b)"},
+		{"-a b", "(and (not a) b)"},
+		{"-(a OR b)", "(not (or a b))"},
+		{"--a", "(not (not a))"},
+		{"a - b", "(and a b)"},
+		{"well-known", "well-known"},
+		{`"machine learning" go`, `(and "machine learning" go)`},
+		{`-"breaking news"`, `(not "breaking news")`},
+		{`feed:"Go Blog" is:UNREAD`, `(and feed:"Go Blog" is:unread)`},
+		{"Feed:42 category:tech", "(and feed:42 category:tech)"},
+		{"author:alice tag:go is:later", "(and author:alice tag:go is:later)"},
+		{"after:2024-01-01 before:2025-01-02T00:00:00Z", "(and after:2024-01-01 before:2025-01-02T00:00:00Z)"},
+		{"foo:bar", "foo:bar"},
+		{"-tag:ads OR is:starred", "(or (not tag:ads) is:starred)"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			query, err := parseSearchQuery(test.input)
+			if err != nil {
+				t.Fatalf("parseSearchQuery(%q) error = %v", test.input, err)
+			}
+			if got := renderSearchNode(query.Root); got != test.want {
+				t.Fatalf("parseSearchQuery(%q) = %s, want %s", test.input, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseSearchQueryErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		position int
+		message  string
+	}{
+		{`"open phrase`, 1, "unterminated quoted phrase"},
+		{`a "b`, 3, "unterminated quoted phrase"},
+		{`feed:"Go`, 6, "unterminated quoted phrase"},
+		{"feed:", 1, "missing value for feed:"},
+		{`a tag:""`, 3, "missing value for tag:"},
+		{"a OR", 3, "OR must be followed by a search term"},
+		{"a OR OR b", 3, "OR must be followed by a search term"},
+		{"(a OR) b", 4, "OR must be followed by a search term"},
+		{"OR a", 1, "OR must be preceded by a search term"},
+		{"a -OR b", 3, "- must be followed by a search term"},
+		{"(a b", 1, "unclosed parenthesis"},
+		{"a (b (c)", 3, "unclosed parenthesis"},
+		{"a b)", 4, "unexpected closing parenthesis"},
+		{"()", 2, "expected a search term"},
+		{"is:bogus", 1, `unknown state "bogus"`},
+		{"x before:yesterday", 3, `invalid date "yesterday"`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, err := parseSearchQuery(test.input)
+			var queryErr searchQueryError
+			if !errors.As(err, &queryErr) {
+				t.Fatalf("parseSearchQuery(%q) error = %v, want searchQueryError", test.input, err)
+			}
+			if queryErr.Position != test.position {
+				t.Errorf("position = %d, want %d", queryErr.Position, test.position)
+			}
+			if !strings.Contains(queryErr.Message, test.message) {
+				t.Errorf("message = %q, want %q", queryErr.Message, test.message)
+			}
+		})
+	}
+}
+
+func TestSearchQueryCondition(t *testing.T) {
+	query, err := parseSearchQuery(`go -feed:3 OR "a b" before:2025-01-02`)
+	if err != nil {
+		t.Fatalf("parseSearchQuery() error = %v", err)
+	}
+
+	condition, args, nextIndex := query.condition(4)
+	want := "(((numnode(plainto_tsquery(f.search_config, $4)) = 0 OR i.search_vector @@ plainto_tsquery(f.search_config, $4))" +
+		" AND NOT COALESCE(i.feed_id = $5::bigint, FALSE))" +
+		" OR ((numnode(phraseto_tsquery(f.search_config, $6)) = 0 OR i.search_vector @@ phraseto_tsquery(f.search_config, $6))" +
+		" AND i.published_at < $7))"
+	if condition != want {
+		t.Errorf("condition =\n%s\nwant\n%s", condition, want)
+	}
+	wantArgs := []interface{}{"go", "3", "a b", time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)}
+	if !reflect.DeepEqual(args, wantArgs) {
+		t.Errorf("args = %v, want %v", args, wantArgs)
+	}
+	if nextIndex != 8 {
+		t.Errorf("next index = %d, want 8", nextIndex)
+	}
+	if rank := query.rankText(); rank != `go or "a b"` {
+		t.Errorf("rankText() = %q", rank)
+	}
+}
+
+func TestSearchQueryConditionTerms(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		args  []interface{}
+	}{
+		{
+			input: "the kubernetes",
+			want: "((numnode(plainto_tsquery(f.search_config, $1)) = 0 OR i.search_vector @@ plainto_tsquery(f.search_config, $1))" +
+				" AND (numnode(plainto_tsquery(f.search_config, $2)) = 0 OR i.search_vector @@ plainto_tsquery(f.search_config, $2)))",
+			args: []interface{}{"the", "kubernetes"},
+		},
+		{
+			input: "-the",
+			want:  "NOT COALESCE((numnode(plainto_tsquery(f.search_config, $1)) > 0 AND i.search_vector @@ plainto_tsquery(f.search_config, $1)), FALSE)",
+			args:  []interface{}{"the"},
+		},
+		{
+			input: "--the",
+			want:  "NOT COALESCE(NOT COALESCE((numnode(plainto_tsquery(f.search_config, $1)) = 0 OR i.search_vector @@ plainto_tsquery(f.search_config, $1)), FALSE), FALSE)",
+			args:  []interface{}{"the"},
+		},
+		{
+			input: "feed:go",
+			want:  "f.name ILIKE $1",
+			args:  []interface{}{"go"},
+		},
+		{
+			input: `feed:"Go*"`,
+			want:  "f.name ILIKE $1",
+			args:  []interface{}{"Go%"},
+		},
+		{
+			input: "feed:100%_off",
+			want:  "f.name ILIKE $1",
+			args:  []interface{}{`100\%\_off`},
+		},
+		{
+			input: "category:*dev*",
+			want:  categoryTreeCondition("SELECT id FROM categories WHERE name ILIKE $1"),
+			args:  []interface{}{"%dev%"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			query, err := parseSearchQuery(test.input)
+			if err != nil {
+				t.Fatalf("parseSearchQuery(%q) error = %v", test.input, err)
+			}
+			condition, args, _ := query.condition(1)
+			if condition != test.want {
+				t.Errorf("condition =\n%s\nwant\n%s", condition, test.want)
+			}
+			if !reflect.DeepEqual(args, test.args) {
+				t.Errorf("args = %q, want %q", args, test.args)
+			}
+		})
+	}
+}
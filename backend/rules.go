@@ -0,0 +1,730 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultRuleTestLimit = 200
+	maxRuleTestLimit     = 2000
+)
+
+var (
+	ruleFields    = map[string]bool{"title": true, "summary": true, "link": true, "author": true, "feed": true}
+	ruleOperators = map[string]bool{"contains": true, "regex": true}
+	ruleActions   = map[string]bool{"mark_read": true, "favorite": true, "read_later": true, "tag": true, "drop": true}
+)
+
+type Rule struct {
+	ID         string          `json:"id"`
+	Name       string          `json:"name"`
+	Enabled    bool            `json:"enabled"`
+	FeedID     *string         `json:"feed_id"`
+	CategoryID *string         `json:"category_id"`
+	Match      string          `json:"match"`
+	Conditions []RuleCondition `json:"conditions"`
+	Actions    []RuleAction    `json:"actions"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+type RuleCondition struct {
+	Field    string `json:"field"`
+	Operator string `json:"operator"`
+	Value    string `json:"value"`
+	Negate   bool   `json:"negate"`
+}
+
+type RuleAction struct {
+	Type string `json:"type"`
+	Tag  string `json:"tag,omitempty"`
+}
+
+type ruleRequest struct {
+	Name       *string          `json:"name"`
+	Enabled    *bool            `json:"enabled"`
+	FeedID     *string          `json:"feed_id"`
+	CategoryID *string          `json:"category_id"`
+	Match      *string          `json:"match"`
+	Conditions *[]RuleCondition `json:"conditions"`
+	Actions    *[]RuleAction    `json:"actions"`
+}
+
+type RuleApplyReport struct {
+	Matched     int        `json:"matched"`
+	Read        int64      `json:"read"`
+	Favorited   int64      `json:"favorited"`
+	ReadLater   int64      `json:"read_later"`
+	Tagged      int64      `json:"tagged"`
+	Dropped     int64      `json:"dropped"`
+	OperationID *string    `json:"operation_id"`
+	ExpiresAt   *time.Time `json:"undo_expires_at"`
+}
+
+type RuleTestResult struct {
+	Scanned int             `json:"scanned"`
+	Matches []RuleTestMatch `json:"matches"`
+}
+
+type RuleTestMatch struct {
+	ID          string     `json:"id"`
+	FeedID      string     `json:"feed_id"`
+	FeedName    string     `json:"feed_name"`
+	Title       string     `json:"title"`
+	Link        string     `json:"link"`
+	PublishedAt *time.Time `json:"published_at"`
+}
+
+type ruleSubject struct {
+	Title       string
+	Summary     string
+	Link        string
+	Author      string
+	FeedID      int64
+	FeedName    string
+	CategoryIDs []int64
+}
+
+type ruleOutcome struct {
+	Drop      bool
+	Read      bool
+	Favorite  bool
+	ReadLater bool
+	Tags      []string
+}
+
+type compiledRule struct {
+	Rule       Rule
+	FeedID     *int64
+	CategoryID *int64
+	matchers   []func(ruleSubject) bool
+}
+
+func compileRule(rule Rule) (compiledRule, error) {
+	compiled := compiledRule{Rule: rule}
+	var err error
+	if compiled.FeedID, err = parseOptionalID(rule.FeedID); err != nil {
+		return compiled, errors.New("invalid feed id")
+	}
+	if compiled.CategoryID, err = parseOptionalID(rule.CategoryID); err != nil {
+		return compiled, errors.New("invalid category id")
+	}
+	if rule.Match != "all" && rule.Match != "any" {
+		return compiled, errors.New("match must be all or any")
+	}
+	if len(rule.Conditions) == 0 && compiled.FeedID == nil && compiled.CategoryID == nil {
+		return compiled, errors.New("a rule needs at least one condition or a feed/category scope")
+	}
+	if len(rule.Actions) == 0 {
+		return compiled, errors.New("a rule needs at least one action")
+	}
+
+	for index, condition := range rule.Conditions {
+		if !ruleFields[condition.Field] {
+			return compiled, fmt.Errorf("conditions[%d]: field must be title, summary, link, author or feed", index)
+		}
+		if !ruleOperators[condition.Operator] {
+			return compiled, fmt.Errorf("conditions[%d]: operator must be contains or regex", index)
+		}
+		if strings.TrimSpace(condition.Value) == "" {
+			return compiled, fmt.Errorf("conditions[%d]: value is required", index)
+		}
+		matcher, err := ruleMatcher(condition)
+		if err != nil {
+			return compiled, fmt.Errorf("conditions[%d]: %v", index, err)
+		}
+		compiled.matchers = append(compiled.matchers, matcher)
+	}
+	for index, action := range rule.Actions {
+		if !ruleActions[action.Type] {
+			return compiled, fmt.Errorf("actions[%d]: type must be mark_read, favorite, read_later, tag or drop", index)
+		}
+		if action.Type == "tag" && strings.TrimSpace(action.Tag) == "" {
+			return compiled, fmt.Errorf("actions[%d]: tag is required", index)
+		}
+	}
+	return compiled, nil
+}
+
+func ruleMatcher(condition RuleCondition) (func(ruleSubject) bool, error) {
+	field := condition.Field
+	value := func(subject ruleSubject) string {
+		switch field {
+		case "title":
+			return subject.Title
+		case "summary":
+			return subject.Summary
+		case "link":
+			return subject.Link
+		case "author":
+			return subject.Author
+		}
+		return subject.FeedName
+	}
+
+	var match func(string) bool
+	if condition.Operator == "regex" {
+		pattern, err := regexp.Compile(condition.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex: %v", err)
+		}
+		match = pattern.MatchString
+	} else {
+		keyword := strings.ToLower(condition.Value)
+		match = func(text string) bool {
+			return strings.Contains(strings.ToLower(text), keyword)
+		}
+	}
+	negate := condition.Negate
+	return func(subject ruleSubject) bool {
+		return match(value(subject)) != negate
+	}, nil
+}
+
+func (r compiledRule) inScope(subject ruleSubject) bool {
+	if r.FeedID != nil && *r.FeedID != subject.FeedID {
+		return false
+	}
+	if r.CategoryID == nil {
+		return true
+	}
+	for _, categoryID := range subject.CategoryIDs {
+		if categoryID == *r.CategoryID {
+			return true
+		}
+	}
+	return false
+}
+
+func (r compiledRule) matches(subject ruleSubject) bool {
+	if !r.inScope(subject) {
+		return false
+	}
+	if len(r.matchers) == 0 {
+		return true
+	}
+	for _, matcher := range r.matchers {
+		matched := matcher(subject)
+		if matched && r.Rule.Match == "any" {
+			return true
+		}
+		if !matched && r.Rule.Match == "all" {
+			return false
+		}
+	}
+	return r.Rule.Match == "all"
+}
+
+func (r compiledRule) apply(outcome *ruleOutcome) {
+	for _, action := range r.Rule.Actions {
+		switch action.Type {
+		case "mark_read":
+			outcome.Read = true
+		case "favorite":
+			outcome.Favorite = true
+		case "read_later":
+			outcome.ReadLater = true
+		case "tag":
+			outcome.Tags = append(outcome.Tags, strings.TrimSpace(action.Tag))
+		case "drop":
+			outcome.Drop = true
+		}
+	}
+}
+
+func evaluateRules(rules []compiledRule, subject ruleSubject) ruleOutcome {
+	var outcome ruleOutcome
+	for _, rule := range rules {
+		if rule.matches(subject) {
+			rule.apply(&outcome)
+		}
+	}
+	return outcome
+}
+
+const ruleColumns = `id, name, enabled, feed_id, category_id, match_mode, conditions, actions, created_at`
+
+func scanRule(scanner interface{ Scan(...interface{}) error }) (Rule, error) {
+	var rule Rule
+	var ruleID int64
+	var feedID, categoryID sql.NullInt64
+	var conditions, actions []byte
+	if err := scanner.Scan(&ruleID, &rule.Name, &rule.Enabled, &feedID, &categoryID, &rule.Match, &conditions, &actions, &rule.CreatedAt); err != nil {
+		return rule, err
+	}
+	rule.ID = formatID(ruleID)
+	rule.FeedID = formatNullableID(feedID)
+	rule.CategoryID = formatNullableID(categoryID)
+	if err := json.Unmarshal(conditions, &rule.Conditions); err != nil {
+		return rule, err
+	}
+	if err := json.Unmarshal(actions, &rule.Actions); err != nil {
+		return rule, err
+	}
+	return rule, nil
+}
+
+func (s *Server) loadRule(ctx context.Context, id int64) (Rule, error) {
+	return scanRule(s.db.QueryRowContext(ctx, `SELECT `+ruleColumns+` FROM rules WHERE id = $1`, id))
+}
+
+func listRules(ctx context.Context, q queryer, enabledOnly bool) ([]Rule, error) {
+	query := `SELECT ` + ruleColumns + ` FROM rules`
+	if enabledOnly {
+		query += ` WHERE enabled = TRUE`
+	}
+	rows, err := q.QueryContext(ctx, query+` ORDER BY id ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make([]Rule, 0)
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *Server) loadIngestRules(ctx context.Context, feedID int64) ([]compiledRule, ruleSubject, error) {
+	base := ruleSubject{FeedID: feedID}
+	var categoryID sql.NullInt64
+	if err := s.db.QueryRowContext(ctx, `SELECT name, category_id FROM feeds WHERE id = $1`, feedID).Scan(&base.FeedName, &categoryID); err != nil {
+		return nil, base, err
+	}
+	if categoryID.Valid {
+		ancestors, err := categoryAncestors(ctx, s.db, categoryID.Int64)
+		if err != nil {
+			return nil, base, err
+		}
+		base.CategoryIDs = ancestors
+	}
+
+	rules, err := listRules(ctx, s.db, true)
+	if err != nil {
+		return nil, base, err
+	}
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		compiledRule, err := compileRule(rule)
+		if err != nil || !compiledRule.inScope(base) {
+			continue
+		}
+		compiled = append(compiled, compiledRule)
+	}
+	return compiled, base, nil
+}
+
+func applyRuleOutcome(ctx context.Context, db dbExecutor, itemID int64, outcome ruleOutcome) error {
+	if outcome.ReadLater {
+		if _, err := db.ExecContext(ctx, `INSERT INTO read_later (item_id) VALUES ($1) ON CONFLICT (item_id) DO NOTHING`, itemID); err != nil {
+			return err
+		}
+	}
+	if len(outcome.Tags) > 0 {
+		if _, err := attachTags(ctx, db, []int64{itemID}, outcome.Tags); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *Server) matchRuleItems(ctx context.Context, rule compiledRule, limit int) ([]RuleTestMatch, []int64, int, error) {
+	conditions := []string{}
+	args := []interface{}{}
+	argIndex := 1
+	if rule.FeedID != nil {
+		conditions = append(conditions, "i.feed_id = $"+strconv.Itoa(argIndex))
+		args = append(args, *rule.FeedID)
+		argIndex++
+	}
+	if rule.CategoryID != nil {
+		conditions = append(conditions, categoryTreeCondition("SELECT $"+strconv.Itoa(argIndex)+"::bigint"))
+		args = append(args, *rule.CategoryID)
+		argIndex++
+	}
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+	limitClause := ""
+	if limit > 0 {
+		limitClause = "LIMIT $" + strconv.Itoa(argIndex)
+		args = append(args, limit)
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT i.id, i.title, COALESCE(i.summary, ''), i.link, COALESCE(i.author, ''), i.published_at, f.id, f.name, f.category_id
+		FROM items i
+		JOIN feeds f ON f.id = i.feed_id
+		`+whereClause+`
+		ORDER BY i.created_at DESC, i.id DESC
+		`+limitClause, args...)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer rows.Close()
+
+	matches := make([]RuleTestMatch, 0)
+	itemIDs := make([]int64, 0)
+	ancestors := make(map[int64][]int64)
+	scanned := 0
+	for rows.Next() {
+		var itemID int64
+		var subject ruleSubject
+		var publishedAt *time.Time
+		var categoryID sql.NullInt64
+		if err := rows.Scan(&itemID, &subject.Title, &subject.Summary, &subject.Link, &subject.Author, &publishedAt, &subject.FeedID, &subject.FeedName, &categoryID); err != nil {
+			return nil, nil, 0, err
+		}
+		if categoryID.Valid {
+			if _, ok := ancestors[categoryID.Int64]; !ok {
+				chain, err := categoryAncestors(ctx, s.db, categoryID.Int64)
+				if err != nil {
+					return nil, nil, 0, err
+				}
+				ancestors[categoryID.Int64] = chain
+			}
+			subject.CategoryIDs = ancestors[categoryID.Int64]
+		}
+		scanned++
+		if !rule.matches(subject) {
+			continue
+		}
+		itemIDs = append(itemIDs, itemID)
+		matches = append(matches, RuleTestMatch{
+			ID:          formatID(itemID),
+			FeedID:      formatID(subject.FeedID),
+			FeedName:    subject.FeedName,
+			Title:       subject.Title,
+			Link:        subject.Link,
+			PublishedAt: publishedAt,
+		})
+	}
+	return matches, itemIDs, scanned, rows.Err()
+}
+
+func (s *Server) handleListRules(c *gin.Context) {
+	rules, err := listRules(c.Request.Context(), s.db, false)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, rules)
+}
+
+func (s *Server) handleGetRule(c *gin.Context) {
+	ruleID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+	rule, err := s.loadRule(c.Request.Context(), ruleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, rule)
+}
+
+func (req ruleRequest) applyTo(rule *Rule) {
+	if req.Name != nil {
+		rule.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.FeedID != nil {
+		rule.FeedID = normalizedOptionalID(*req.FeedID)
+	}
+	if req.CategoryID != nil {
+		rule.CategoryID = normalizedOptionalID(*req.CategoryID)
+	}
+	if req.Match != nil {
+		rule.Match = strings.TrimSpace(*req.Match)
+	}
+	if req.Conditions != nil {
+		rule.Conditions = *req.Conditions
+	}
+	if req.Actions != nil {
+		rule.Actions = *req.Actions
+	}
+}
+
+func normalizedOptionalID(value string) *string {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "0" {
+		return nil
+	}
+	return &value
+}
+
+func newRule() Rule {
+	return Rule{Enabled: true, Match: "all", Conditions: make([]RuleCondition, 0), Actions: make([]RuleAction, 0)}
+}
+
+func (s *Server) handleCreateRule(c *gin.Context) {
+	var req ruleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	rule := newRule()
+	req.applyTo(&rule)
+	s.saveRule(c, rule, 0)
+}
+
+func (s *Server) handleUpdateRule(c *gin.Context) {
+	ruleID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+	var req ruleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	rule, err := s.loadRule(c.Request.Context(), ruleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	req.applyTo(&rule)
+	s.saveRule(c, rule, ruleID)
+}
+
+func (s *Server) saveRule(c *gin.Context, rule Rule, ruleID int64) {
+	if rule.Name == "" {
+		respondErrorMessage(c, http.StatusBadRequest, "name is required")
+		return
+	}
+	compiled, err := compileRule(rule)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	conditions, err := json.Marshal(rule.Conditions)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	actions, err := json.Marshal(rule.Actions)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	args := []interface{}{rule.Name, rule.Enabled, compiled.FeedID, compiled.CategoryID, rule.Match, string(conditions), string(actions)}
+	status := http.StatusOK
+	var row *sql.Row
+	if ruleID == 0 {
+		status = http.StatusCreated
+		row = s.db.QueryRow(`
+			INSERT INTO rules (name, enabled, feed_id, category_id, match_mode, conditions, actions)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)
+			RETURNING `+ruleColumns, args...)
+	} else {
+		row = s.db.QueryRow(`
+			UPDATE rules SET name = $1, enabled = $2, feed_id = $3, category_id = $4, match_mode = $5, conditions = $6, actions = $7
+			WHERE id = $8
+			RETURNING `+ruleColumns, append(args, ruleID)...)
+	}
+	saved, err := scanRule(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, status, saved)
+}
+
+func (s *Server) handleDeleteRule(c *gin.Context) {
+	ruleID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+
+	result, err := s.db.Exec(`DELETE FROM rules WHERE id = $1`, ruleID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	respondSuccess(c, http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handleTestRule(c *gin.Context) {
+	var req ruleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	rule := newRule()
+	req.applyTo(&rule)
+	compiled, err := compileRule(rule)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+
+	limit := parsePositiveInt(c.Query("limit"), defaultRuleTestLimit)
+	if limit > maxRuleTestLimit {
+		limit = maxRuleTestLimit
+	}
+	matches, _, scanned, err := s.matchRuleItems(c.Request.Context(), compiled, limit)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, RuleTestResult{Scanned: scanned, Matches: matches})
+}
+
+func (s *Server) handleApplyRule(c *gin.Context) {
+	ruleID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid rule id")
+		return
+	}
+	rule, err := s.loadRule(c.Request.Context(), ruleID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	compiled, err := compileRule(rule)
+	if err != nil {
+		respondError(c, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	ctx := c.Request.Context()
+	_, itemIDs, _, err := s.matchRuleItems(ctx, compiled, 0)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	report := RuleApplyReport{Matched: len(itemIDs)}
+	if len(itemIDs) == 0 {
+		respondSuccess(c, http.StatusOK, report)
+		return
+	}
+
+	var outcome ruleOutcome
+	compiled.apply(&outcome)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	exec := func(query string, args ...interface{}) int64 {
+		if err != nil {
+			return 0
+		}
+		var result sql.Result
+		result, err = tx.ExecContext(ctx, query, args...)
+		if err != nil {
+			return 0
+		}
+		count, _ := result.RowsAffected()
+		return count
+	}
+	queryCount := func(query string, args ...interface{}) int64 {
+		var count int64
+		if err == nil {
+			err = tx.QueryRowContext(ctx, query, args...).Scan(&count)
+		}
+		return count
+	}
+	if outcome.Drop {
+		report.Dropped = queryCount(`
+			WITH removed AS (
+				DELETE FROM items i
+				WHERE i.id = ANY($1) AND i.is_favorite = FALSE
+					AND NOT EXISTS (SELECT 1 FROM annotations a WHERE a.item_id = i.id)
+					AND NOT EXISTS (SELECT 1 FROM item_tags it WHERE it.item_id = i.id)
+					AND NOT EXISTS (SELECT 1 FROM read_later rl WHERE rl.item_id = i.id)
+				RETURNING i.feed_id, i.is_read, i.is_favorite
+			), counted AS (
+				`+feedCounterChange("removed", "-(NOT is_read)::integer", "-is_favorite::integer", "-1")+`
+			)
+			SELECT COUNT(*) FROM removed
+		`, pqArray(itemIDs))
+	} else {
+		if outcome.Read && err == nil {
+			var operation ReadOperation
+			operation, err = s.setReadState(ctx, tx, "rule:"+formatID(ruleID), true, []string{"i.id = ANY($1)"}, []interface{}{pqArray(itemIDs)})
+			report.Read = operation.Updated
+			report.OperationID = operation.OperationID
+			report.ExpiresAt = operation.ExpiresAt
+		}
+		if outcome.Favorite {
+			report.Favorited = queryCount(`
+				WITH changed AS (
+					UPDATE items SET is_favorite = TRUE
+					WHERE id = ANY($1) AND is_favorite = FALSE
+					RETURNING feed_id
+				), counted AS (
+					`+feedCounterChange("changed", "0", "1", "0")+`
+				)
+				SELECT COUNT(*) FROM changed
+			`, pqArray(itemIDs))
+		}
+		if outcome.ReadLater {
+			report.ReadLater = exec(`
+				INSERT INTO read_later (item_id)
+				SELECT item_id FROM unnest($1::bigint[]) AS item_id
+				ON CONFLICT (item_id) DO NOTHING
+			`, pqArray(itemIDs))
+		}
+		if len(outcome.Tags) > 0 && err == nil {
+			report.Tagged, err = attachTags(ctx, tx, itemIDs, outcome.Tags)
+		}
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, report)
+}
@@ -0,0 +1,391 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type SavedSearch struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	CategoryID *string    `json:"category_id"`
+	FeedID     *string    `json:"feed_id"`
+	Tag        *string    `json:"tag"`
+	Query      string     `json:"q"`
+	Unread     bool       `json:"unread"`
+	Favorite   bool       `json:"favorite"`
+	Since      *time.Time `json:"since"`
+	Until      *time.Time `json:"until"`
+	DateField  string     `json:"date_field"`
+	CreatedAt  time.Time  `json:"created_at"`
+
+	categoryID sql.NullInt64
+	feedID     sql.NullInt64
+}
+
+type SavedSearchCount struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Unread int    `json:"unread"`
+}
+
+type savedSearchRequest struct {
+	Name       *string `json:"name"`
+	CategoryID *string `json:"category_id"`
+	FeedID     *string `json:"feed_id"`
+	Tag        *string `json:"tag"`
+	Query      *string `json:"q"`
+	Unread     *bool   `json:"unread"`
+	Favorite   *bool   `json:"favorite"`
+	Since      *string `json:"since"`
+	Until      *string `json:"until"`
+	DateField  *string `json:"date_field"`
+}
+
+const savedSearchColumns = `id, name, category_id, feed_id, tag, query, unread_only, favorite_only, since, until, date_field, created_at`
+
+func scanSavedSearch(scanner interface{ Scan(...interface{}) error }) (SavedSearch, error) {
+	var savedSearch SavedSearch
+	var savedSearchID int64
+	if err := scanner.Scan(
+		&savedSearchID,
+		&savedSearch.Name,
+		&savedSearch.categoryID,
+		&savedSearch.feedID,
+		&savedSearch.Tag,
+		&savedSearch.Query,
+		&savedSearch.Unread,
+		&savedSearch.Favorite,
+		&savedSearch.Since,
+		&savedSearch.Until,
+		&savedSearch.DateField,
+		&savedSearch.CreatedAt,
+	); err != nil {
+		return savedSearch, err
+	}
+	savedSearch.ID = formatID(savedSearchID)
+	savedSearch.CategoryID = formatNullableID(savedSearch.categoryID)
+	savedSearch.FeedID = formatNullableID(savedSearch.feedID)
+	return savedSearch, nil
+}
+
+func (s SavedSearch) filter() ItemFilter {
+	filter := ItemFilter{Unread: s.Unread, Favorite: s.Favorite, Since: s.Since, Until: s.Until, DateField: s.DateField}
+	if s.categoryID.Valid {
+		filter.CategoryID = &s.categoryID.Int64
+	}
+	if s.feedID.Valid {
+		filter.FeedID = &s.feedID.Int64
+	}
+	if s.Tag != nil {
+		filter.Tag = *s.Tag
+	}
+	if query := strings.TrimSpace(s.Query); query != "" {
+		filter.Queries = []string{query}
+	}
+	return filter
+}
+
+func (s *Server) loadSavedSearch(ctx context.Context, id int64) (SavedSearch, error) {
+	row := s.db.QueryRowContext(ctx, `SELECT `+savedSearchColumns+` FROM saved_searches WHERE id = $1`, id)
+	return scanSavedSearch(row)
+}
+
+func listSavedSearches(ctx context.Context, q queryer) ([]SavedSearch, error) {
+	rows, err := q.QueryContext(ctx, `SELECT `+savedSearchColumns+` FROM saved_searches ORDER BY name ASC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	savedSearches := make([]SavedSearch, 0)
+	for rows.Next() {
+		savedSearch, err := scanSavedSearch(rows)
+		if err != nil {
+			return nil, err
+		}
+		savedSearches = append(savedSearches, savedSearch)
+	}
+	return savedSearches, rows.Err()
+}
+
+func (s *Server) handleListSavedSearches(c *gin.Context) {
+	savedSearches, err := listSavedSearches(c.Request.Context(), s.db)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, savedSearches)
+}
+
+func (s *Server) handleGetSavedSearch(c *gin.Context) {
+	savedSearchID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid saved search id")
+		return
+	}
+	savedSearch, err := s.loadSavedSearch(c.Request.Context(), savedSearchID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, savedSearch)
+}
+
+func (s *Server) handleCreateSavedSearch(c *gin.Context) {
+	var req savedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.Name == nil || strings.TrimSpace(*req.Name) == "" {
+		respondErrorMessage(c, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	columns, values, args, ok := savedSearchAssignments(c, req)
+	if !ok {
+		return
+	}
+
+	savedSearch, err := scanSavedSearch(s.db.QueryRow(`
+		INSERT INTO saved_searches (`+strings.Join(columns, ", ")+`)
+		VALUES (`+strings.Join(values, ", ")+`)
+		RETURNING `+savedSearchColumns, args...))
+	if isUniqueViolation(err) {
+		respondErrorMessage(c, http.StatusConflict, "saved search name already exists")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusCreated, savedSearch)
+}
+
+func (s *Server) handleUpdateSavedSearch(c *gin.Context) {
+	savedSearchID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid saved search id")
+		return
+	}
+
+	var req savedSearchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if req.Name != nil && strings.TrimSpace(*req.Name) == "" {
+		respondErrorMessage(c, http.StatusBadRequest, "name cannot be empty")
+		return
+	}
+
+	columns, values, args, ok := savedSearchAssignments(c, req)
+	if !ok {
+		return
+	}
+	if len(columns) == 0 {
+		respondErrorMessage(c, http.StatusBadRequest, "no fields to update")
+		return
+	}
+	setClauses := make([]string, len(columns))
+	for index, column := range columns {
+		setClauses[index] = column + " = " + values[index]
+	}
+	args = append(args, savedSearchID)
+
+	savedSearch, err := scanSavedSearch(s.db.QueryRow(`
+		UPDATE saved_searches SET `+strings.Join(setClauses, ", ")+`
+		WHERE id = $`+strconv.Itoa(len(args))+`
+		RETURNING `+savedSearchColumns, args...))
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if isUniqueViolation(err) {
+		respondErrorMessage(c, http.StatusConflict, "saved search name already exists")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, savedSearch)
+}
+
+func (s *Server) handleDeleteSavedSearch(c *gin.Context) {
+	savedSearchID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid saved search id")
+		return
+	}
+
+	result, err := s.db.Exec(`DELETE FROM saved_searches WHERE id = $1`, savedSearchID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	respondSuccess(c, http.StatusOK, gin.H{"status": "ok"})
+}
+
+func savedSearchAssignments(c *gin.Context, req savedSearchRequest) ([]string, []string, []interface{}, bool) {
+	columns := []string{}
+	values := []string{}
+	args := []interface{}{}
+	add := func(column string, value interface{}) {
+		columns = append(columns, column)
+		if value == nil {
+			values = append(values, "NULL")
+			return
+		}
+		args = append(args, value)
+		values = append(values, "$"+strconv.Itoa(len(args)))
+	}
+
+	if req.Name != nil {
+		add("name", strings.TrimSpace(*req.Name))
+	}
+	for _, field := range []struct {
+		column  string
+		value   *string
+		message string
+	}{
+		{"category_id", req.CategoryID, "invalid category id"},
+		{"feed_id", req.FeedID, "invalid feed id"},
+	} {
+		if field.value == nil {
+			continue
+		}
+		id, err := parseOptionalID(field.value)
+		if err != nil {
+			respondErrorMessage(c, http.StatusBadRequest, field.message)
+			return nil, nil, nil, false
+		}
+		if id == nil || *id == 0 {
+			add(field.column, nil)
+		} else {
+			add(field.column, *id)
+		}
+	}
+	if req.Tag != nil {
+		if tag := strings.TrimSpace(*req.Tag); tag == "" {
+			add("tag", nil)
+		} else {
+			add("tag", tag)
+		}
+	}
+	if req.Query != nil {
+		query := strings.TrimSpace(*req.Query)
+		if _, err := parseSearchQuery(query); err != nil {
+			var queryErr searchQueryError
+			if errors.As(err, &queryErr) {
+				respondErrorData(c, http.StatusBadRequest, queryErr.Error(), queryErr)
+			} else {
+				respondError(c, http.StatusBadRequest, err)
+			}
+			return nil, nil, nil, false
+		}
+		add("query", query)
+	}
+	if req.Unread != nil {
+		add("unread_only", *req.Unread)
+	}
+	if req.Favorite != nil {
+		add("favorite_only", *req.Favorite)
+	}
+	for _, field := range []struct {
+		column string
+		value  *string
+	}{
+		{"since", req.Since},
+		{"until", req.Until},
+	} {
+		if field.value == nil {
+			continue
+		}
+		parsed, err := parseFilterTime(*field.value)
+		if err != nil {
+			respondErrorMessage(c, http.StatusBadRequest, "invalid "+field.column)
+			return nil, nil, nil, false
+		}
+		if parsed == nil {
+			add(field.column, nil)
+		} else {
+			add(field.column, *parsed)
+		}
+	}
+	if req.DateField != nil {
+		switch dateField := strings.TrimSpace(*req.DateField); dateField {
+		case "published", "fetched":
+			add("date_field", dateField)
+		case "":
+			add("date_field", "published")
+		default:
+			respondErrorMessage(c, http.StatusBadRequest, "date_field must be published or fetched")
+			return nil, nil, nil, false
+		}
+	}
+	return columns, values, args, true
+}
+
+func (s *Server) savedSearchUnreadCounts(ctx context.Context, base ItemFilter) (int, []SavedSearchCount, error) {
+	savedSearches, err := listSavedSearches(ctx, s.db)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	filters := make([]ItemFilter, 0, len(savedSearches)+1)
+	filters = append(filters, base)
+	for _, savedSearch := range savedSearches {
+		filters = append(filters, savedSearch.filter())
+	}
+
+	columns := make([]string, 0, len(filters))
+	args := []interface{}{}
+	argIndex := 1
+	for _, filter := range filters {
+		conditions, filterArgs, nextIndex, _, err := buildItemConditions(filter, argIndex)
+		if err != nil {
+			return 0, nil, err
+		}
+		condition := "TRUE"
+		if len(conditions) > 0 {
+			condition = strings.Join(conditions, " AND ")
+		}
+		columns = append(columns, "COUNT(*) FILTER (WHERE "+condition+")")
+		args = append(args, filterArgs...)
+		argIndex = nextIndex
+	}
+
+	counts := make([]int, len(columns))
+	targets := make([]interface{}, len(columns))
+	for index := range counts {
+		targets[index] = &counts[index]
+	}
+	query := `SELECT ` + strings.Join(columns, ", ") + ` FROM items i JOIN feeds f ON f.id = i.feed_id WHERE i.is_read = FALSE`
+	if err := s.db.QueryRowContext(ctx, query, args...).Scan(targets...); err != nil {
+		return 0, nil, err
+	}
+
+	folderCounts := make([]SavedSearchCount, 0, len(savedSearches))
+	for index, savedSearch := range savedSearches {
+		folderCounts = append(folderCounts, SavedSearchCount{ID: savedSearch.ID, Name: savedSearch.Name, Unread: counts[index+1]})
+	}
+	return counts[0], folderCounts, nil
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"strings"
+)
+
+const searchHeadlineOptions = "MaxFragments=2, MaxWords=30, MinWords=10, StartSel=<mark>, StopSel=</mark>"
+
+var textSearchConfigs = map[string]string{
+	"da": "danish",
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fi": "finnish",
+	"fr": "french",
+	"hu": "hungarian",
+	"it": "italian",
+	"nb": "norwegian",
+	"nl": "dutch",
+	"nn": "norwegian",
+	"no": "norwegian",
+	"pt": "portuguese",
+	"ro": "romanian",
+	"ru": "russian",
+	"sv": "swedish",
+	"tr": "turkish",
+}
+
+func normalizeLanguage(value string) string {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if index := strings.IndexAny(value, "-_"); index >= 0 {
+		value = value[:index]
+	}
+	return value
+}
+
+func textSearchConfig(language string) string {
+	if config, ok := textSearchConfigs[normalizeLanguage(language)]; ok {
+		return config
+	}
+	return "simple"
+}
+
+func itemSearchVector(config, title, summary string) string {
+	return "setweight(to_tsvector(" + config + ", COALESCE(" + title + ", '')), 'A') || setweight(to_tsvector(" + config + ", COALESCE(" + summary + ", '')), 'B')"
+}
+
+var reindexFeedSearchQuery = `
+	UPDATE items i SET search_vector = ` + itemSearchVector("f.search_config", "i.title", "i.summary") + `
+	FROM feeds f
+	WHERE f.id = i.feed_id AND i.feed_id = $1
+`
+
+func languageValue(language string) interface{} {
+	if normalized := normalizeLanguage(language); normalized != "" {
+		return normalized
+	}
+	return nil
+}
+
+func (s *Server) setFeedLanguage(ctx context.Context, feedID int64, language string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	if _, err := tx.ExecContext(ctx,
+		`UPDATE feeds SET language = $2, search_config = $3::regconfig WHERE id = $1`,
+		feedID, languageValue(language), textSearchConfig(language),
+	); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, reindexFeedSearchQuery, feedID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
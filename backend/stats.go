@@ -0,0 +1,221 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type FeedStats struct {
+	FeedID             string            `json:"feed_id"`
+	FeedName           string            `json:"feed_name"`
+	WindowDays         int               `json:"window_days"`
+	Items              int64             `json:"items"`
+	WindowItems        int64             `json:"window_items"`
+	ItemsPerDay        float64           `json:"items_per_day"`
+	ItemsPerWeek       float64           `json:"items_per_week"`
+	AvgHoursBetween    *float64          `json:"avg_hours_between_posts"`
+	LastItemAt         *time.Time        `json:"last_item_at"`
+	HoursSinceLastItem *float64          `json:"hours_since_last_item"`
+	ReadRatio          float64           `json:"read_ratio"`
+	FavoriteRatio      float64           `json:"favorite_ratio"`
+	OpenRatio          float64           `json:"open_ratio"`
+	Clicks             int64             `json:"clicks"`
+	FetchCount         int64             `json:"fetch_count"`
+	FetchErrors        int64             `json:"fetch_errors"`
+	FetchSuccessRate   *float64          `json:"fetch_success_rate"`
+	LastFetchedAt      *time.Time        `json:"last_fetched_at"`
+	Daily              []FeedStatsBucket `json:"daily,omitempty"`
+	Weekly             []FeedStatsBucket `json:"weekly,omitempty"`
+}
+
+type FeedStatsBucket struct {
+	Start time.Time `json:"start"`
+	Items int64     `json:"items"`
+}
+
+const itemPostedAt = "COALESCE(i.published_at, i.created_at)"
+
+func statsWindowDays(c *gin.Context) (int, bool) {
+	days := 90
+	if value := strings.TrimSpace(c.Query("days")); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > 365 {
+			respondErrorMessage(c, http.StatusBadRequest, "days must be between 1 and 365")
+			return 0, false
+		}
+		days = parsed
+	}
+	return days, true
+}
+
+func ratio(part int64, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) / float64(total)
+}
+
+func (s *Server) loadFeedStats(ctx context.Context, days int, feedID *int64) ([]FeedStats, error) {
+	conditions := []string{}
+	args := []interface{}{days}
+	argIndex := 2
+	if feedID != nil {
+		conditions = append(conditions, "f.id = $"+strconv.Itoa(argIndex))
+		args = append(args, *feedID)
+		argIndex++
+	}
+	whereClause := ""
+	if len(conditions) > 0 {
+		whereClause = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	window := itemPostedAt + ` >= NOW() - make_interval(days => $1)`
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT f.id, f.name, f.fetch_count, f.fetch_error_count, f.last_fetched_at,
+			COUNT(i.id),
+			COUNT(i.id) FILTER (WHERE i.is_read),
+			COUNT(i.id) FILTER (WHERE i.is_favorite),
+			COUNT(i.id) FILTER (WHERE i.click_count > 0),
+			COALESCE(SUM(i.click_count), 0),
+			COUNT(i.id) FILTER (WHERE `+window+`),
+			EXTRACT(EPOCH FROM MAX(`+itemPostedAt+`) FILTER (WHERE `+window+`) - MIN(`+itemPostedAt+`) FILTER (WHERE `+window+`))::double precision,
+			MAX(i.created_at)
+		FROM feeds f
+		LEFT JOIN items i ON i.feed_id = f.id
+		`+whereClause+`
+		GROUP BY f.id
+		ORDER BY f.name ASC, f.id ASC
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	stats := make([]FeedStats, 0)
+	for rows.Next() {
+		entry := FeedStats{WindowDays: days}
+		var id int64
+		var readItems, favoriteItems, openedItems int64
+		var windowSeconds sql.NullFloat64
+		if err := rows.Scan(
+			&id,
+			&entry.FeedName,
+			&entry.FetchCount,
+			&entry.FetchErrors,
+			&entry.LastFetchedAt,
+			&entry.Items,
+			&readItems,
+			&favoriteItems,
+			&openedItems,
+			&entry.Clicks,
+			&entry.WindowItems,
+			&windowSeconds,
+			&entry.LastItemAt,
+		); err != nil {
+			return nil, err
+		}
+		entry.FeedID = formatID(id)
+		entry.ItemsPerDay = float64(entry.WindowItems) / float64(days)
+		entry.ItemsPerWeek = entry.ItemsPerDay * 7
+		if entry.WindowItems > 1 && windowSeconds.Valid {
+			hours := windowSeconds.Float64 / 3600 / float64(entry.WindowItems-1)
+			entry.AvgHoursBetween = &hours
+		}
+		if entry.LastItemAt != nil {
+			hours := now.Sub(*entry.LastItemAt).Hours()
+			entry.HoursSinceLastItem = &hours
+		}
+		entry.ReadRatio = ratio(readItems, entry.Items)
+		entry.FavoriteRatio = ratio(favoriteItems, entry.Items)
+		entry.OpenRatio = ratio(openedItems, entry.Items)
+		if entry.FetchCount > 0 {
+			rate := ratio(entry.FetchCount-entry.FetchErrors, entry.FetchCount)
+			entry.FetchSuccessRate = &rate
+		}
+		stats = append(stats, entry)
+	}
+	return stats, rows.Err()
+}
+
+func (s *Server) feedStatsBuckets(ctx context.Context, feedID int64, days int, unit string) ([]FeedStatsBucket, error) {
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT b.start, COUNT(i.id)
+		FROM generate_series(
+			date_trunc($3::text, NOW() - make_interval(days => $2)),
+			date_trunc($3::text, NOW()),
+			('1 ' || $3::text)::interval
+		) AS b(start)
+		LEFT JOIN items i ON i.feed_id = $1
+			AND `+itemPostedAt+` >= NOW() - make_interval(days => $2)
+			AND date_trunc($3::text, `+itemPostedAt+`) = b.start
+		GROUP BY b.start
+		ORDER BY b.start ASC
+	`, feedID, days, unit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	buckets := make([]FeedStatsBucket, 0)
+	for rows.Next() {
+		var bucket FeedStatsBucket
+		if err := rows.Scan(&bucket.Start, &bucket.Items); err != nil {
+			return nil, err
+		}
+		buckets = append(buckets, bucket)
+	}
+	return buckets, rows.Err()
+}
+
+func (s *Server) handleFeedStats(c *gin.Context) {
+	feedID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid feed id")
+		return
+	}
+	days, ok := statsWindowDays(c)
+	if !ok {
+		return
+	}
+
+	ctx := c.Request.Context()
+	stats, err := s.loadFeedStats(ctx, days, &feedID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if len(stats) == 0 {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	entry := stats[0]
+	if entry.Daily, err = s.feedStatsBuckets(ctx, feedID, days, "day"); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if entry.Weekly, err = s.feedStatsBuckets(ctx, feedID, days, "week"); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, entry)
+}
+
+func (s *Server) handleListFeedStats(c *gin.Context) {
+	days, ok := statsWindowDays(c)
+	if !ok {
+		return
+	}
+	stats, err := s.loadFeedStats(c.Request.Context(), days, nil)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, stats)
+}
@@ -0,0 +1,490 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+type Tag struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Items     int       `json:"item_count"`
+	Unread    int       `json:"unread_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type tagRequest struct {
+	Name string `json:"name"`
+}
+
+type mergeTagRequest struct {
+	TargetID string `json:"target_id"`
+}
+
+type itemTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+type batchTagRequest struct {
+	ItemIDs []string `json:"item_ids"`
+	Add     []string `json:"add"`
+	Remove  []string `json:"remove"`
+}
+
+type dbExecutor interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+const tagCountQuery = `
+	SELECT t.id, t.name, t.created_at,
+		COUNT(i.id),
+		COUNT(i.id) FILTER (WHERE i.is_read = FALSE)
+	FROM tags t
+	LEFT JOIN item_tags it ON it.tag_id = t.id
+	LEFT JOIN items i ON i.id = it.item_id
+`
+
+const itemTagNamesColumn = `ARRAY(SELECT t.name FROM item_tags it JOIN tags t ON t.id = it.tag_id WHERE it.item_id = i.id ORDER BY t.name)`
+
+func scanTag(scanner interface{ Scan(...interface{}) error }) (Tag, error) {
+	var tag Tag
+	var tagID int64
+	if err := scanner.Scan(&tagID, &tag.Name, &tag.CreatedAt, &tag.Items, &tag.Unread); err != nil {
+		return tag, err
+	}
+	tag.ID = formatID(tagID)
+	return tag, nil
+}
+
+func (s *Server) loadTag(ctx context.Context, id int64) (Tag, error) {
+	return scanTag(s.db.QueryRowContext(ctx, tagCountQuery+` WHERE t.id = $1 GROUP BY t.id`, id))
+}
+
+func normalizeTagNames(names []string) []string {
+	normalized := make([]string, 0, len(names))
+	seen := make(map[string]bool, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		normalized = append(normalized, name)
+	}
+	return normalized
+}
+
+func upsertTag(ctx context.Context, db dbExecutor, name string) (int64, error) {
+	var tagID int64
+	err := db.QueryRowContext(ctx, `
+		INSERT INTO tags (name) VALUES ($1)
+		ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+		RETURNING id
+	`, strings.TrimSpace(name)).Scan(&tagID)
+	return tagID, err
+}
+
+func attachTags(ctx context.Context, db dbExecutor, itemIDs []int64, names []string) (int64, error) {
+	var attached int64
+	for _, name := range normalizeTagNames(names) {
+		tagID, err := upsertTag(ctx, db, name)
+		if err != nil {
+			return attached, err
+		}
+		result, err := db.ExecContext(ctx, `
+			INSERT INTO item_tags (item_id, tag_id)
+			SELECT i.id, $2 FROM items i WHERE i.id = ANY($1)
+			ON CONFLICT DO NOTHING
+		`, pqArray(itemIDs), tagID)
+		if err != nil {
+			return attached, err
+		}
+		count, _ := result.RowsAffected()
+		attached += count
+	}
+	return attached, nil
+}
+
+func detachTags(ctx context.Context, db dbExecutor, itemIDs []int64, names []string) (int64, error) {
+	result, err := db.ExecContext(ctx, `
+		DELETE FROM item_tags it
+		USING tags t
+		WHERE t.id = it.tag_id AND it.item_id = ANY($1) AND t.name = ANY($2)
+	`, pqArray(itemIDs), pq.Array(normalizeTagNames(names)))
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func renameTagReferences(ctx context.Context, db dbExecutor, from string, to string) error {
+	if _, err := db.ExecContext(ctx, `UPDATE saved_searches SET tag = $2 WHERE tag = $1`, from, to); err != nil {
+		return err
+	}
+	_, err := db.ExecContext(ctx, `
+		UPDATE rules SET actions = (
+			SELECT jsonb_agg(CASE
+				WHEN action->>'type' = 'tag' AND action->>'tag' = $1 THEN jsonb_set(action, '{tag}', to_jsonb($2::text))
+				ELSE action
+			END)
+			FROM jsonb_array_elements(actions) AS action
+		)
+		WHERE actions @> jsonb_build_array(jsonb_build_object('type', 'tag', 'tag', $1::text))
+	`, from, to)
+	return err
+}
+
+func (s *Server) handleListTags(c *gin.Context) {
+	rows, err := s.db.Query(tagCountQuery + ` GROUP BY t.id ORDER BY t.name ASC`)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer rows.Close()
+
+	tags := make([]Tag, 0)
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		tags = append(tags, tag)
+	}
+	if err := rows.Err(); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, tags)
+}
+
+func (s *Server) handleCreateTag(c *gin.Context) {
+	var req tagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		respondErrorMessage(c, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	var tag Tag
+	var tagID int64
+	err := s.db.QueryRow(`INSERT INTO tags (name) VALUES ($1) RETURNING id, name, created_at`, name).Scan(&tagID, &tag.Name, &tag.CreatedAt)
+	if isUniqueViolation(err) {
+		respondErrorMessage(c, http.StatusConflict, "tag already exists")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	tag.ID = formatID(tagID)
+	respondSuccess(c, http.StatusCreated, tag)
+}
+
+func (s *Server) handleRenameTag(c *gin.Context) {
+	tagID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid tag id")
+		return
+	}
+	var req tagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		respondErrorMessage(c, http.StatusBadRequest, "name is required")
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	var previous string
+	err = tx.QueryRowContext(ctx, `SELECT name FROM tags WHERE id = $1 FOR UPDATE`, tagID).Scan(&previous)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err == nil {
+		_, err = tx.ExecContext(ctx, `UPDATE tags SET name = $1 WHERE id = $2`, name, tagID)
+	}
+	if isUniqueViolation(err) {
+		respondErrorMessage(c, http.StatusConflict, "tag already exists")
+		return
+	}
+	if err == nil {
+		err = renameTagReferences(ctx, tx, previous, name)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	tag, err := s.loadTag(ctx, tagID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, tag)
+}
+
+func (s *Server) handleMergeTag(c *gin.Context) {
+	sourceID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid tag id")
+		return
+	}
+	var req mergeTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	targetID, err := parseIDParam(strings.TrimSpace(req.TargetID))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid target id")
+		return
+	}
+	if targetID == sourceID {
+		respondErrorMessage(c, http.StatusBadRequest, "cannot merge a tag into itself")
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	names := map[int64]string{}
+	rows, err := tx.QueryContext(ctx, `SELECT id, name FROM tags WHERE id IN ($1, $2) FOR UPDATE`, sourceID, targetID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	for rows.Next() {
+		var id int64
+		var name string
+		if err := rows.Scan(&id, &name); err != nil {
+			rows.Close()
+			respondError(c, http.StatusInternalServerError, err)
+			return
+		}
+		names[id] = name
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if len(names) != 2 {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	if _, err = tx.ExecContext(ctx, `
+		INSERT INTO item_tags (item_id, tag_id, created_at)
+		SELECT item_id, $2, created_at FROM item_tags WHERE tag_id = $1
+		ON CONFLICT DO NOTHING
+	`, sourceID, targetID); err == nil {
+		_, err = tx.ExecContext(ctx, `DELETE FROM tags WHERE id = $1`, sourceID)
+	}
+	if err == nil {
+		err = renameTagReferences(ctx, tx, names[sourceID], names[targetID])
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	tag, err := s.loadTag(ctx, targetID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, tag)
+}
+
+func (s *Server) handleDeleteTag(c *gin.Context) {
+	tagID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid tag id")
+		return
+	}
+
+	result, err := s.db.Exec(`DELETE FROM tags WHERE id = $1`, tagID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	respondSuccess(c, http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) itemTagNames(ctx context.Context, itemID int64) ([]string, error) {
+	names := make([]string, 0)
+	err := s.db.QueryRowContext(ctx, `SELECT `+itemTagNamesColumn+` FROM items i WHERE i.id = $1`, itemID).Scan(pq.Array(&names))
+	return names, err
+}
+
+func (s *Server) handleListItemTags(c *gin.Context) {
+	itemID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid item id")
+		return
+	}
+	names, err := s.itemTagNames(c.Request.Context(), itemID)
+	if errors.Is(err, sql.ErrNoRows) {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, names)
+}
+
+func (s *Server) handleAttachItemTags(c *gin.Context) {
+	itemID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid item id")
+		return
+	}
+	var req itemTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(normalizeTagNames(req.Tags)) == 0 {
+		respondErrorMessage(c, http.StatusBadRequest, "tags is required")
+		return
+	}
+
+	ctx := c.Request.Context()
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS (SELECT 1 FROM items WHERE id = $1)`, itemID).Scan(&exists); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if !exists {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+	if _, err := attachTags(ctx, s.db, []int64{itemID}, req.Tags); err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+
+	names, err := s.itemTagNames(ctx, itemID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, names)
+}
+
+func (s *Server) handleDetachItemTag(c *gin.Context) {
+	itemID, err := parseIDParam(c.Param("id"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid item id")
+		return
+	}
+	tagID, err := parseIDParam(c.Param("tagID"))
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid tag id")
+		return
+	}
+
+	result, err := s.db.Exec(`DELETE FROM item_tags WHERE item_id = $1 AND tag_id = $2`, itemID, tagID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	if count, _ := result.RowsAffected(); count == 0 {
+		respondErrorMessage(c, http.StatusNotFound, "not found")
+		return
+	}
+
+	respondSuccess(c, http.StatusOK, gin.H{"status": "ok"})
+}
+
+func (s *Server) handleBatchTag(c *gin.Context) {
+	var req batchTagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.ItemIDs) == 0 {
+		respondErrorMessage(c, http.StatusBadRequest, "item_ids is required")
+		return
+	}
+	if len(normalizeTagNames(req.Add)) == 0 && len(normalizeTagNames(req.Remove)) == 0 {
+		respondErrorMessage(c, http.StatusBadRequest, "add or remove is required")
+		return
+	}
+
+	itemIDs, err := parseIDSlice(req.ItemIDs)
+	if err != nil {
+		respondErrorMessage(c, http.StatusBadRequest, "invalid item ids")
+		return
+	}
+
+	ctx := c.Request.Context()
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	added, err := attachTags(ctx, tx, itemIDs, req.Add)
+	var removed int64
+	if err == nil {
+		removed, err = detachTags(ctx, tx, itemIDs, req.Remove)
+	}
+	if err == nil {
+		err = tx.Commit()
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, err)
+		return
+	}
+	respondSuccess(c, http.StatusOK, gin.H{"added": added, "removed": removed})
+}
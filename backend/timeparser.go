@@ -0,0 +1,217 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var timeLayouts = []string{
+	"2 Jan 2006 15:04:05",
+	"2 Jan 2006 15:04",
+	"2 Jan 2006 3:04:05 PM",
+	"2 Jan 2006 3:04 PM",
+	"2 Jan 06 15:04:05",
+	"2 Jan 06 15:04",
+	"2 Jan 2006",
+	"Jan 2 2006 15:04:05",
+	"Jan 2 2006 15:04",
+	"Jan 2 2006 3:04:05 PM",
+	"Jan 2 2006 3:04 PM",
+	"Jan 2 2006",
+	"Jan 2 15:04:05 2006",
+	"2006 Jan 2 15:04:05",
+	"2006 Jan 2",
+	"2006-1-2T15:04:05",
+	"2006-1-2T15:04",
+	"2006-1-2 15:04:05",
+	"2006-1-2 15:04",
+	"2006-1-2",
+	"2006/1/2 15:04:05",
+	"2006/1/2 15:04",
+	"2006/1/2",
+	"2.1.2006 15:04:05",
+	"2.1.2006 15:04",
+	"2.1.2006",
+	"2-Jan-2006 15:04:05",
+	"2-Jan-06 15:04:05",
+	"2006年1月2日 15:04:05",
+	"2006年1月2日 15:04",
+	"2006年1月2日",
+}
+
+var zoneOffsets = map[string]int{
+	"UT":   0,
+	"UTC":  0,
+	"GMT":  0,
+	"Z":    0,
+	"WET":  0,
+	"WEST": 1 * 3600,
+	"BST":  1 * 3600,
+	"CET":  1 * 3600,
+	"MET":  1 * 3600,
+	"CEST": 2 * 3600,
+	"MEST": 2 * 3600,
+	"EET":  2 * 3600,
+	"EEST": 3 * 3600,
+	"MSK":  3 * 3600,
+	"IST":  5*3600 + 1800,
+	"ICT":  7 * 3600,
+	"WIB":  7 * 3600,
+	"HKT":  8 * 3600,
+	"SGT":  8 * 3600,
+	"AWST": 8 * 3600,
+	"JST":  9 * 3600,
+	"KST":  9 * 3600,
+	"ACST": 9*3600 + 1800,
+	"ACDT": 10*3600 + 1800,
+	"AEST": 10 * 3600,
+	"AEDT": 11 * 3600,
+	"NZST": 12 * 3600,
+	"NZDT": 13 * 3600,
+	"NST":  -(3*3600 + 1800),
+	"NDT":  -(2*3600 + 1800),
+	"AST":  -4 * 3600,
+	"ADT":  -3 * 3600,
+	"EST":  -5 * 3600,
+	"EDT":  -4 * 3600,
+	"CST":  -6 * 3600,
+	"CDT":  -5 * 3600,
+	"MST":  -7 * 3600,
+	"MDT":  -6 * 3600,
+	"PST":  -8 * 3600,
+	"PDT":  -7 * 3600,
+	"AKST": -9 * 3600,
+	"AKDT": -8 * 3600,
+	"HST":  -10 * 3600,
+}
+
+var monthNames = map[string]string{
+	"jan": "Jan", "january": "Jan", "januar": "Jan", "jänner": "Jan", "jän": "Jan", "janvier": "Jan", "janv": "Jan",
+	"enero": "Jan", "ene": "Jan", "gennaio": "Jan", "gen": "Jan", "janeiro": "Jan", "januari": "Jan",
+	"feb": "Feb", "february": "Feb", "februar": "Feb", "février": "Feb", "fevrier": "Feb", "févr": "Feb", "fevr": "Feb",
+	"febrero": "Feb", "febbraio": "Feb", "fevereiro": "Feb", "fev": "Feb", "februari": "Feb",
+	"mar": "Mar", "march": "Mar", "märz": "Mar", "mär": "Mar", "mrz": "Mar", "mars": "Mar", "marzo": "Mar",
+	"março": "Mar", "marco": "Mar", "maart": "Mar", "mrt": "Mar",
+	"apr": "Apr", "april": "Apr", "avril": "Apr", "avr": "Apr", "abril": "Apr", "abr": "Apr", "aprile": "Apr",
+	"may": "May", "mai": "May", "mayo": "May", "maggio": "May", "mag": "May", "maio": "May", "mei": "May",
+	"jun": "Jun", "june": "Jun", "juni": "Jun", "juin": "Jun", "junio": "Jun", "giugno": "Jun", "giu": "Jun", "junho": "Jun",
+	"jul": "Jul", "july": "Jul", "juli": "Jul", "juillet": "Jul", "juil": "Jul", "julio": "Jul", "luglio": "Jul",
+	"lug": "Jul", "julho": "Jul",
+	"aug": "Aug", "august": "Aug", "août": "Aug", "aout": "Aug", "agosto": "Aug", "ago": "Aug", "augustus": "Aug",
+	"sep": "Sep", "sept": "Sep", "september": "Sep", "septembre": "Sep", "septiembre": "Sep", "setiembre": "Sep",
+	"settembre": "Sep", "set": "Sep", "setembro": "Sep",
+	"oct": "Oct", "october": "Oct", "oktober": "Oct", "okt": "Oct", "octobre": "Oct", "octubre": "Oct",
+	"ottobre": "Oct", "ott": "Oct", "outubro": "Oct", "out": "Oct",
+	"nov": "Nov", "november": "Nov", "novembre": "Nov", "noviembre": "Nov", "novembro": "Nov",
+	"dec": "Dec", "december": "Dec", "dezember": "Dec", "dez": "Dec", "décembre": "Dec", "decembre": "Dec",
+	"déc": "Dec", "diciembre": "Dec", "dic": "Dec", "dicembre": "Dec", "dezembro": "Dec",
+}
+
+var ignoredDateWords = map[string]bool{
+	"mon": true, "monday": true, "tue": true, "tues": true, "tuesday": true, "wed": true, "wednesday": true,
+	"thu": true, "thur": true, "thurs": true, "thursday": true, "fri": true, "friday": true,
+	"sat": true, "saturday": true, "sun": true, "sunday": true,
+	"montag": true, "dienstag": true, "mittwoch": true, "donnerstag": true, "freitag": true, "samstag": true, "sonntag": true,
+	"mo": true, "di": true, "mi": true, "do": true, "fr": true, "sa": true, "so": true,
+	"lundi": true, "mardi": true, "mercredi": true, "jeudi": true, "vendredi": true, "samedi": true, "dimanche": true,
+	"lun": true, "mer": true, "jeu": true, "ven": true, "sam": true, "dim": true,
+	"lunes": true, "martes": true, "miércoles": true, "miercoles": true, "jueves": true, "viernes": true,
+	"sábado": true, "sabado": true, "domingo": true, "mié": true, "jue": true, "vie": true, "sáb": true, "dom": true,
+	"lunedì": true, "martedì": true, "mercoledì": true, "giovedì": true, "venerdì": true, "sabato": true, "domenica": true,
+	"segunda": true, "terça": true, "quarta": true, "quinta": true, "sexta": true, "feira": true,
+	"maandag": true, "dinsdag": true, "woensdag": true, "donderdag": true, "vrijdag": true, "zaterdag": true, "zondag": true,
+	"ma": true, "wo": true, "vr": true, "za": true, "zo": true,
+	"at": true, "de": true, "del": true, "um": true, "à": true, "le": true, "den": true, "der": true,
+}
+
+var (
+	dateWordPattern     = regexp.MustCompile(`\p{L}+\.?`)
+	dateOrdinalPattern  = regexp.MustCompile(`(\d)(?:st|nd|rd|th|er)\b`)
+	dateDayDotPattern   = regexp.MustCompile(`(\d)\.(\s)`)
+	dateCommentPattern  = regexp.MustCompile(`\s*\([^)]*\)$`)
+	numericZonePattern  = regexp.MustCompile(`^(.*\d)\s*([+-])(\d{2}):?(\d{2})$`)
+	prefixedZonePattern = regexp.MustCompile(`(?i)^(.*?)\s*(?:GMT|UTC|UT)\s*([+-])(\d{1,2})(?::?(\d{2}))?$`)
+	namedZonePattern    = regexp.MustCompile(`^(.*[\d\s])([A-Za-z]{1,5})$`)
+	earliestPublishedAt = time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC)
+)
+
+func parseTime(value string) *time.Time {
+	value = strings.Join(strings.Fields(value), " ")
+	if value == "" {
+		return nil
+	}
+
+	rest, offset := splitTimeZone(value)
+	rest = normalizeDateWords(rest)
+	for _, layout := range timeLayouts {
+		parsed, err := time.ParseInLocation(layout, rest, time.UTC)
+		if err != nil {
+			continue
+		}
+		if offset != 0 {
+			parsed = time.Date(parsed.Year(), parsed.Month(), parsed.Day(), parsed.Hour(), parsed.Minute(), parsed.Second(), parsed.Nanosecond(), time.FixedZone("", offset))
+		}
+		if !parsed.After(earliestPublishedAt) {
+			return nil
+		}
+		return &parsed
+	}
+	return nil
+}
+
+func splitTimeZone(value string) (string, int) {
+	value = dateCommentPattern.ReplaceAllString(value, "")
+
+	if match := numericZonePattern.FindStringSubmatch(value); match != nil {
+		return strings.TrimSpace(match[1]), zoneOffset(match[2], match[3], match[4])
+	}
+	if match := prefixedZonePattern.FindStringSubmatch(value); match != nil {
+		return strings.TrimSpace(match[1]), zoneOffset(match[2], match[3], match[4])
+	}
+	if match := namedZonePattern.FindStringSubmatch(value); match != nil {
+		if offset, ok := zoneOffsets[strings.ToUpper(match[2])]; ok {
+			return strings.TrimSpace(match[1]), offset
+		}
+	}
+	return value, 0
+}
+
+func zoneOffset(sign, hours, minutes string) int {
+	h, _ := strconv.Atoi(hours)
+	m, _ := strconv.Atoi(minutes)
+	offset := h*3600 + m*60
+	if sign == "-" {
+		return -offset
+	}
+	return offset
+}
+
+func normalizeDateWords(value string) string {
+	value = dateOrdinalPattern.ReplaceAllString(value, "$1")
+	value = dateDayDotPattern.ReplaceAllString(value, "$1$2")
+	value = dateWordPattern.ReplaceAllStringFunc(value, func(word string) string {
+		key := strings.ToLower(strings.TrimSuffix(word, "."))
+		if month, ok := monthNames[key]; ok {
+			return month
+		}
+		if ignoredDateWords[key] {
+			return ""
+		}
+		switch key {
+		case "am", "pm":
+			return strings.ToUpper(key)
+		}
+		return word
+	})
+	value = strings.ReplaceAll(value, ",", " ")
+	return strings.Join(strings.Fields(value), " ")
+}
+
+func clampPublished(published *time.Time, fetchedAt time.Time) time.Time {
+	if published == nil || published.After(fetchedAt) {
+		return fetchedAt
+	}
+	return *published
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  time.Time
+	}{
+		{"rfc1123 named zone EST", "Mon, 02 Jan 2006 15:04:05 EST", time.Date(2006, 1, 2, 20, 4, 5, 0, time.UTC)},
+		{"named zone PDT", "Tue, 10 Jun 2025 08:30:00 PDT", time.Date(2025, 6, 10, 15, 30, 0, 0, time.UTC)},
+		{"prefixed offset GMT+8", "2024-03-05 09:00:00 GMT+8", time.Date(2024, 3, 5, 1, 0, 0, 0, time.UTC)},
+		{"numeric offset", "Wed, 05 Mar 2024 09:00:00 +0530", time.Date(2024, 3, 5, 3, 30, 0, 0, time.UTC)},
+		{"single digit day without zone", "Fri, 7 Feb 2025 12:00:00", time.Date(2025, 2, 7, 12, 0, 0, 0, time.UTC)},
+		{"time without seconds", "7 Feb 2025 12:34 GMT", time.Date(2025, 2, 7, 12, 34, 0, 0, time.UTC)},
+		{"iso date without zone", "2025-02-07T12:34:56", time.Date(2025, 2, 7, 12, 34, 56, 0, time.UTC)},
+		{"iso date only", "2025-02-07", time.Date(2025, 2, 7, 0, 0, 0, 0, time.UTC)},
+		{"ordinal day", "March 3rd, 2024 10:00", time.Date(2024, 3, 3, 10, 0, 0, 0, time.UTC)},
+		{"german month", "Mo, 3. März 2025 14:15:00 +0100", time.Date(2025, 3, 3, 13, 15, 0, 0, time.UTC)},
+		{"french month", "mardi 4 février 2025 08:00", time.Date(2025, 2, 4, 8, 0, 0, 0, time.UTC)},
+		{"spanish month", "5 de enero de 2025 18:45", time.Date(2025, 1, 5, 18, 45, 0, 0, time.UTC)},
+		{"chinese date", "2025年1月5日 18:45", time.Date(2025, 1, 5, 18, 45, 0, 0, time.UTC)},
+		{"trailing comment", "Sun, 09 Mar 2025 10:00:00 +0000 (UTC)", time.Date(2025, 3, 9, 10, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := parseTime(test.input)
+			if got == nil {
+				t.Fatalf("parseTime(%q) = nil, want %v", test.input, test.want)
+			}
+			if !got.Equal(test.want) {
+				t.Fatalf("parseTime(%q) = %v, want %v", test.input, got.UTC(), test.want)
+			}
+		})
+	}
+}
+
+func TestParseTimeRejects(t *testing.T) {
+	inputs := []string{
+		"",
+		"   ",
+		"not a date",
+		"1 Jan 1970 00:00:00 GMT",
+		"31 Dec 1969 23:59:59 GMT",
+		"1969-07-20",
+	}
+	for _, input := range inputs {
+		if got := parseTime(input); got != nil {
+			t.Errorf("parseTime(%q) = %v, want nil", input, got)
+		}
+	}
+}
+
+func TestClampPublished(t *testing.T) {
+	fetchedAt := time.Date(2025, 5, 1, 12, 0, 0, 0, time.UTC)
+	past := fetchedAt.Add(-time.Hour)
+	future := fetchedAt.Add(time.Hour)
+
+	tests := []struct {
+		name      string
+		published *time.Time
+		want      time.Time
+	}{
+		{"nil uses fetch time", nil, fetchedAt},
+		{"future clamps to fetch time", &future, fetchedAt},
+		{"past is kept", &past, past},
+		{"equal is kept", &fetchedAt, fetchedAt},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := clampPublished(test.published, fetchedAt); !got.Equal(test.want) {
+				t.Fatalf("clampPublished() = %v, want %v", got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,459 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/lib/pq"
+)
+
+type importSet struct {
+	Categories      []string
+	CategoryParents map[string]string
+	Feeds           []importFeed
+	Skipped         []SkippedFeed
+}
+
+type importFeed struct {
+	Name          string
+	URL           string
+	SiteURL       string
+	CategoryName  string
+	FetchInterval *int
+}
+
+const (
+	importStrategyOverwrite      = "overwrite"
+	importStrategyKeepExisting   = "keep_existing"
+	importStrategyFailOnConflict = "fail_on_conflict"
+)
+
+type ImportReport struct {
+	DryRun     bool           `json:"dry_run"`
+	Strategy   string         `json:"strategy"`
+	Categories int            `json:"categories"`
+	Feeds      int            `json:"feeds"`
+	Created    []ImportedFeed `json:"created"`
+	Updated    []ImportedFeed `json:"updated"`
+	Unchanged  []ImportedFeed `json:"unchanged"`
+	Skipped    []SkippedFeed  `json:"skipped"`
+	Diff       ImportDiff     `json:"diff"`
+}
+
+type ImportDiff struct {
+	NewCategories []string     `json:"new_categories"`
+	NewFeeds      []DiffFeed   `json:"new_feeds"`
+	ChangedFeeds  []FeedChange `json:"changed_feeds"`
+}
+
+type DiffFeed struct {
+	Name         string  `json:"name"`
+	URL          string  `json:"url"`
+	CategoryName *string `json:"category_name"`
+}
+
+type FeedChange struct {
+	ID              string   `json:"id"`
+	URL             string   `json:"url"`
+	Name            string   `json:"name"`
+	CategoryName    *string  `json:"category_name"`
+	NewName         string   `json:"new_name"`
+	NewCategoryName *string  `json:"new_category_name"`
+	ChangedFields   []string `json:"changed_fields"`
+}
+
+type importConflictError struct {
+	report ImportReport
+}
+
+func (e importConflictError) Error() string {
+	return fmt.Sprintf("import would change %d existing feeds", len(e.report.Diff.ChangedFeeds))
+}
+
+type existingFeed struct {
+	ID            int64
+	Name          string
+	CategoryName  *string
+	SiteURL       *string
+	FetchInterval int
+}
+
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type ImportedFeed struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	URL          string  `json:"url"`
+	CategoryName *string `json:"category_name"`
+}
+
+type SkippedFeed struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Reason string `json:"reason"`
+}
+
+func transferImportSet(payload TransferPayload) (importSet, error) {
+	set := importSet{CategoryParents: make(map[string]string), Skipped: make([]SkippedFeed, 0)}
+
+	categoryNameByID := make(map[string]string)
+	for _, category := range payload.Categories {
+		if strings.TrimSpace(category.ID) == "" {
+			continue
+		}
+		categoryNameByID[strings.TrimSpace(category.ID)] = strings.TrimSpace(category.Name)
+	}
+
+	for _, category := range payload.Categories {
+		name := strings.TrimSpace(category.Name)
+		if name == "" {
+			return importSet{}, fmt.Errorf("category name is required")
+		}
+		set.Categories = append(set.Categories, name)
+
+		parentName := ""
+		if category.ParentName != nil {
+			parentName = strings.TrimSpace(*category.ParentName)
+		}
+		if parentName == "" && category.ParentID != nil {
+			parentName = categoryNameByID[strings.TrimSpace(*category.ParentID)]
+		}
+		if parentName != "" && parentName != name {
+			set.CategoryParents[name] = parentName
+		}
+	}
+
+	for _, feed := range payload.Feeds {
+		name := strings.TrimSpace(feed.Name)
+		feedURL := strings.TrimSpace(feed.URL)
+		if name == "" || feedURL == "" {
+			return importSet{}, fmt.Errorf("feed name and url are required")
+		}
+
+		categoryName := ""
+		if feed.CategoryName != nil {
+			categoryName = strings.TrimSpace(*feed.CategoryName)
+		}
+		if categoryName == "" && feed.CategoryID != nil {
+			if mappedName, ok := categoryNameByID[strings.TrimSpace(*feed.CategoryID)]; ok {
+				categoryName = strings.TrimSpace(mappedName)
+			}
+		}
+
+		siteURL := ""
+		if feed.SiteURL != nil {
+			siteURL = strings.TrimSpace(*feed.SiteURL)
+		}
+
+		set.Feeds = append(set.Feeds, importFeed{
+			Name:          name,
+			URL:           feedURL,
+			SiteURL:       siteURL,
+			CategoryName:  categoryName,
+			FetchInterval: validFetchInterval(feed.FetchInterval),
+		})
+	}
+	return set, nil
+}
+
+func validFetchInterval(value *int) *int {
+	if value == nil || *value <= 0 {
+		return nil
+	}
+	return value
+}
+
+func isValidFeedURL(value string) bool {
+	parsed, err := url.Parse(value)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func isValidImportStrategy(strategy string) bool {
+	switch strategy {
+	case importStrategyOverwrite, importStrategyKeepExisting, importStrategyFailOnConflict:
+		return true
+	}
+	return false
+}
+
+func optionalName(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+func sameOptionalName(left, right *string) bool {
+	if left == nil || right == nil {
+		return left == nil && right == nil
+	}
+	return *left == *right
+}
+
+func planImport(ctx context.Context, q queryer, set importSet) (ImportDiff, map[string]existingFeed, error) {
+	diff := ImportDiff{
+		NewCategories: make([]string, 0),
+		NewFeeds:      make([]DiffFeed, 0),
+		ChangedFeeds:  make([]FeedChange, 0),
+	}
+
+	categoryNames := make([]string, 0, len(set.Categories)+len(set.Feeds))
+	categoryNames = append(categoryNames, set.Categories...)
+	for _, name := range set.Categories {
+		if parentName, ok := set.CategoryParents[name]; ok {
+			categoryNames = append(categoryNames, parentName)
+		}
+	}
+	feedURLs := make([]string, 0, len(set.Feeds))
+	for _, feed := range set.Feeds {
+		if feed.CategoryName != "" {
+			categoryNames = append(categoryNames, feed.CategoryName)
+		}
+		feedURLs = append(feedURLs, feed.URL)
+	}
+
+	existingCategories := make(map[string]bool)
+	categoryRows, err := q.QueryContext(ctx, `SELECT name FROM categories WHERE name = ANY($1)`, pq.Array(categoryNames))
+	if err != nil {
+		return diff, nil, err
+	}
+	defer categoryRows.Close()
+	for categoryRows.Next() {
+		var name string
+		if err := categoryRows.Scan(&name); err != nil {
+			return diff, nil, err
+		}
+		existingCategories[name] = true
+	}
+	if err := categoryRows.Err(); err != nil {
+		return diff, nil, err
+	}
+	for _, name := range categoryNames {
+		if !existingCategories[name] {
+			existingCategories[name] = true
+			diff.NewCategories = append(diff.NewCategories, name)
+		}
+	}
+
+	existingFeeds := make(map[string]existingFeed)
+	feedRows, err := q.QueryContext(ctx, `
+		SELECT f.id, f.url, f.name, c.name, f.site_url, f.fetch_interval_minutes
+		FROM feeds f
+		LEFT JOIN categories c ON c.id = f.category_id
+		WHERE f.url = ANY($1)
+	`, pq.Array(feedURLs))
+	if err != nil {
+		return diff, nil, err
+	}
+	defer feedRows.Close()
+	for feedRows.Next() {
+		var feed existingFeed
+		var feedURL string
+		if err := feedRows.Scan(&feed.ID, &feedURL, &feed.Name, &feed.CategoryName, &feed.SiteURL, &feed.FetchInterval); err != nil {
+			return diff, nil, err
+		}
+		existingFeeds[feedURL] = feed
+	}
+	if err := feedRows.Err(); err != nil {
+		return diff, nil, err
+	}
+
+	for _, feed := range set.Feeds {
+		newCategoryName := optionalName(feed.CategoryName)
+		existing, ok := existingFeeds[feed.URL]
+		if !ok {
+			diff.NewFeeds = append(diff.NewFeeds, DiffFeed{Name: feed.Name, URL: feed.URL, CategoryName: newCategoryName})
+			continue
+		}
+		changedFields := make([]string, 0, 4)
+		if existing.Name != feed.Name {
+			changedFields = append(changedFields, "name")
+		}
+		if !sameOptionalName(existing.CategoryName, newCategoryName) {
+			changedFields = append(changedFields, "category")
+		}
+		if feed.SiteURL != "" && !sameOptionalName(existing.SiteURL, &feed.SiteURL) {
+			changedFields = append(changedFields, "site_url")
+		}
+		if feed.FetchInterval != nil && *feed.FetchInterval != existing.FetchInterval {
+			changedFields = append(changedFields, "fetch_interval_minutes")
+		}
+		if len(changedFields) > 0 {
+			diff.ChangedFeeds = append(diff.ChangedFeeds, FeedChange{
+				ID:              formatID(existing.ID),
+				URL:             feed.URL,
+				Name:            existing.Name,
+				CategoryName:    existing.CategoryName,
+				NewName:         feed.Name,
+				NewCategoryName: newCategoryName,
+				ChangedFields:   changedFields,
+			})
+		}
+	}
+	return diff, existingFeeds, nil
+}
+
+func (s *Server) previewImport(ctx context.Context, set importSet, strategy string) (ImportReport, error) {
+	report := newImportReport(set, strategy)
+	report.DryRun = true
+	diff, _, err := planImport(ctx, s.db, set)
+	if err != nil {
+		return report, err
+	}
+	report.Diff = diff
+	return report, nil
+}
+
+func newImportReport(set importSet, strategy string) ImportReport {
+	report := ImportReport{
+		Strategy:   strategy,
+		Categories: len(set.Categories),
+		Created:    make([]ImportedFeed, 0),
+		Updated:    make([]ImportedFeed, 0),
+		Unchanged:  make([]ImportedFeed, 0),
+		Skipped:    set.Skipped,
+	}
+	if report.Skipped == nil {
+		report.Skipped = make([]SkippedFeed, 0)
+	}
+	return report
+}
+
+func (s *Server) applyImport(ctx context.Context, set importSet, strategy string) (ImportReport, error) {
+	report := newImportReport(set, strategy)
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return report, err
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	diff, existingFeeds, err := planImport(ctx, tx, set)
+	if err != nil {
+		return report, err
+	}
+	report.Diff = diff
+	if strategy == importStrategyFailOnConflict && len(diff.ChangedFeeds) > 0 {
+		return report, importConflictError{report: report}
+	}
+
+	changedURLs := make(map[string]bool, len(diff.ChangedFeeds))
+	for _, change := range diff.ChangedFeeds {
+		changedURLs[change.URL] = true
+	}
+	categoryIDByName := make(map[string]int64)
+	upsertCategory := func(name string) (int64, error) {
+		if categoryID, ok := categoryIDByName[name]; ok {
+			return categoryID, nil
+		}
+		var categoryID int64
+		if err := tx.QueryRowContext(ctx,
+			`INSERT INTO categories (name) VALUES ($1)
+			ON CONFLICT (name) DO UPDATE SET name = EXCLUDED.name
+			RETURNING id`,
+			name,
+		).Scan(&categoryID); err != nil {
+			return 0, err
+		}
+		categoryIDByName[name] = categoryID
+		return categoryID, nil
+	}
+
+	for _, name := range set.Categories {
+		if _, err := upsertCategory(name); err != nil {
+			return report, err
+		}
+	}
+
+	for _, feed := range set.Feeds {
+		categoryName := optionalName(feed.CategoryName)
+		if existing, ok := existingFeeds[feed.URL]; ok && (strategy == importStrategyKeepExisting || !changedURLs[feed.URL]) {
+			report.Unchanged = append(report.Unchanged, ImportedFeed{
+				ID:           formatID(existing.ID),
+				Name:         existing.Name,
+				URL:          feed.URL,
+				CategoryName: existing.CategoryName,
+			})
+			continue
+		}
+
+		var categoryID *int64
+		if feed.CategoryName != "" {
+			id, err := upsertCategory(feed.CategoryName)
+			if err != nil {
+				return report, err
+			}
+			categoryID = &id
+		}
+
+		var feedID int64
+		var inserted bool
+		if err := tx.QueryRowContext(ctx,
+			`INSERT INTO feeds (name, url, site_url, category_id, fetch_interval_minutes)
+			VALUES ($1, $2, $3, $4, COALESCE($5::integer, $6::integer))
+			ON CONFLICT (url) DO UPDATE SET
+				name = EXCLUDED.name,
+				category_id = EXCLUDED.category_id,
+				site_url = COALESCE(EXCLUDED.site_url, feeds.site_url),
+				fetch_interval_minutes = COALESCE($5::integer, feeds.fetch_interval_minutes)
+			RETURNING id, (xmax = 0)`,
+			feed.Name,
+			feed.URL,
+			sql.NullString{String: feed.SiteURL, Valid: feed.SiteURL != ""},
+			categoryID,
+			feed.FetchInterval,
+			s.config.FetchIntervalMinutes,
+		).Scan(&feedID, &inserted); err != nil {
+			return report, err
+		}
+
+		imported := ImportedFeed{ID: formatID(feedID), Name: feed.Name, URL: feed.URL, CategoryName: categoryName}
+		if inserted {
+			report.Created = append(report.Created, imported)
+		} else if changedURLs[feed.URL] {
+			report.Updated = append(report.Updated, imported)
+		}
+		report.Feeds++
+	}
+
+	for _, name := range set.Categories {
+		parentName, ok := set.CategoryParents[name]
+		if !ok {
+			continue
+		}
+		categoryID, err := upsertCategory(name)
+		if err != nil {
+			return report, err
+		}
+		parentID, err := upsertCategory(parentName)
+		if err != nil {
+			return report, err
+		}
+		cycle, err := isCategoryInTree(ctx, tx, categoryID, parentID)
+		if err != nil {
+			return report, err
+		}
+		if cycle {
+			continue
+		}
+		query := `UPDATE categories SET parent_id = $2 WHERE id = $1`
+		if strategy == importStrategyKeepExisting {
+			query += ` AND parent_id IS NULL`
+		}
+		if _, err := tx.ExecContext(ctx, query, categoryID, parentID); err != nil {
+			return report, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return report, err
+	}
+	return report, nil
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"io"
+	"net/url"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+func resolveBase(parent, xmlBase string) string {
+	if strings.TrimSpace(xmlBase) == "" {
+		return parent
+	}
+	return resolveURL(parent, xmlBase)
+}
+
+func resolveURL(base, ref string) string {
+	ref = strings.TrimSpace(ref)
+	if ref == "" {
+		return ""
+	}
+	refURL, err := url.Parse(ref)
+	if err != nil || refURL.IsAbs() {
+		return ref
+	}
+	baseURL, err := url.Parse(strings.TrimSpace(base))
+	if err != nil || !baseURL.IsAbs() {
+		return ref
+	}
+	return baseURL.ResolveReference(refURL).String()
+}
+
+func resolveHTMLURLs(base, fragment string) string {
+	if base == "" || !strings.Contains(fragment, "<") {
+		return fragment
+	}
+
+	var builder strings.Builder
+	tokenizer := html.NewTokenizer(strings.NewReader(fragment))
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if tokenizer.Err() == io.EOF {
+				return builder.String()
+			}
+			return fragment
+		}
+
+		raw := tokenizer.Raw()
+		if tokenType != html.StartTagToken && tokenType != html.SelfClosingTagToken {
+			builder.Write(raw)
+			continue
+		}
+
+		rawTag := string(raw)
+		token := tokenizer.Token()
+		changed := false
+		for index, attr := range token.Attr {
+			if attr.Namespace != "" || (attr.Key != "href" && attr.Key != "src") {
+				continue
+			}
+			if resolved := resolveURL(base, attr.Val); resolved != attr.Val {
+				token.Attr[index].Val = resolved
+				changed = true
+			}
+		}
+		if changed {
+			builder.WriteString(token.String())
+		} else {
+			builder.WriteString(rawTag)
+		}
+	}
+}
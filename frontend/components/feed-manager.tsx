@@ -135,7 +135,7 @@ export function FeedManager({
   });
 
   const deleteCategory = useMutation({
-    mutationFn: api.deleteCategory,
+    mutationFn: (id: string) => api.deleteCategory(id),
     onMutate: async (id) => {
       await queryClient.cancelQueries({ queryKey: queryKeys.categories });
       const previous = queryClient.getQueryData<Category[]>(queryKeys.categories) ?? [];
@@ -173,6 +173,7 @@ export function FeedManager({
         last_fetched_at: null,
         last_status: null,
         last_error: null,
+        last_warning: null,
         category_name: categories.find((category) => category.id === payload.category_id)?.name ?? null,
       };
       queryClient.setQueryData(queryKeys.feeds(selectedCategory), [...previous, optimistic]);
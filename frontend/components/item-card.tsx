@@ -3,6 +3,7 @@
 import DOMPurify from "dompurify";
 import { Bookmark, BookmarkCheck } from "lucide-react";
 import { Button } from "@/components/ui/button";
+import { itemOpenURL } from "@/lib/api";
 import type { Item } from "@/lib/types";
 
 type ItemCardProps = {
@@ -27,7 +28,7 @@ export function ItemCard({ item, onMarkRead, onToggleFavorite }: ItemCardProps)
             />
             <span className="sr-only">{item.is_read ? "Read" : "Unread"}</span>
             <a
-              href={item.link}
+              href={itemOpenURL(item.id)}
               className="min-w-0 truncate hover:underline"
               target="_blank"
               rel="noreferrer"
@@ -46,12 +46,9 @@ export function ItemList({ categoryId, feedId, search, unreadOnly, favoriteOnly
   const itemsQuery = useInfiniteQuery<ItemsResponse>({
     queryKey: queryKeys.items(params),
     queryFn: ({ pageParam }) =>
-      api.listItems({ ...params, page: pageParam as number, page_size: PAGE_SIZE }),
-    initialPageParam: 1,
-    getNextPageParam: (lastPage) => {
-      const totalPages = Math.ceil(lastPage.total / lastPage.page_size);
-      return lastPage.page < totalPages ? lastPage.page + 1 : undefined;
-    },
+      api.listItems({ ...params, cursor: pageParam as string, page_size: PAGE_SIZE }),
+    initialPageParam: "",
+    getNextPageParam: (lastPage) => lastPage.next_cursor ?? undefined,
   });
 
   const allItems = itemsQuery.data?.pages.flatMap((page) => page.items) ?? [];
@@ -1,7 +1,9 @@
-import type { Category, Feed, ItemsResponse, TransferPayload } from "@/lib/types";
+import type { Category, Counters, Feed, FeedStats, ItemSort, ItemsResponse, ReadOperation, TransferPayload } from "@/lib/types";
 
 const API_BASE = process.env.NEXT_PUBLIC_API_BASE_URL ?? "/api";
 
+export const itemOpenURL = (id: string) => `${API_BASE}/items/${id}/open`;
+
 type ApiResponse<T> = {
   code: number;
   message: string;
@@ -30,13 +32,32 @@ async function request<T>(path: string, options?: RequestInit): Promise<T> {
 
 export const api = {
   listCategories: () => request<Category[]>("/categories"),
-  createCategory: (payload: { name: string }) =>
+  createCategory: (payload: { name: string; parent_id?: string | null }) =>
     request<Category>("/categories", {
       method: "POST",
       body: JSON.stringify(payload),
     }),
-  deleteCategory: (id: string) =>
-    request<{ status: string }>(`/categories/${id}`, { method: "DELETE" }),
+  updateCategory: (id: string, payload: { name?: string; parent_id?: string | null }) =>
+    request<Category>(`/categories/${id}`, {
+      method: "PATCH",
+      body: JSON.stringify(payload),
+    }),
+  mergeCategory: (id: string, targetId: string) =>
+    request<{ category: Category; moved_feeds: number }>(`/categories/${id}/merge`, {
+      method: "POST",
+      body: JSON.stringify({ target_id: targetId }),
+    }),
+  deleteCategory: (id: string, options: { moveTo?: string; deleteFeeds?: boolean } = {}) => {
+    const params = new URLSearchParams();
+    if (options.moveTo) {
+      params.set("move_to", options.moveTo);
+    }
+    if (options.deleteFeeds) {
+      params.set("delete_feeds", "true");
+    }
+    const query = params.toString() ? `?${params.toString()}` : "";
+    return request<{ status: string; affected_feeds: number }>(`/categories/${id}${query}`, { method: "DELETE" });
+  },
   listFeeds: (categoryId?: string | null) => {
     const query = categoryId ? `?category_id=${categoryId}` : "";
     return request<Feed[]>(`/feeds${query}`);
@@ -61,22 +82,24 @@ export const api = {
       body: JSON.stringify(payload),
     }),
   listItems: (params: {
-    page: number;
+    cursor: string;
     page_size: number;
     category_id?: string | null;
     feed_id?: string | null;
     q?: string;
     unread?: boolean;
     favorite?: boolean;
+    sort?: ItemSort;
   }) => {
     const query = new URLSearchParams();
-    query.set("page", String(params.page));
+    query.set("cursor", params.cursor);
     query.set("page_size", String(params.page_size));
     if (params.category_id != null) query.set("category_id", String(params.category_id));
     if (params.feed_id != null) query.set("feed_id", String(params.feed_id));
     if (params.q) query.set("q", params.q);
     if (params.unread) query.set("unread", "true");
     if (params.favorite) query.set("favorite", "true");
+    if (params.sort) query.set("sort", params.sort);
     return request<ItemsResponse>(`/items?${query.toString()}`);
   },
   updateItemRead: (id: string, payload: { read: boolean }) =>
@@ -85,10 +108,14 @@ export const api = {
       body: JSON.stringify(payload),
     }),
   batchRead: (payload: { item_ids: string[]; read: boolean }) =>
-    request<{ status: string }>("/items/read-batch", {
+    request<ReadOperation>("/items/read-batch", {
       method: "POST",
       body: JSON.stringify(payload),
     }),
+  undoOperation: (id: string) =>
+    request<{ restored: number }>(`/operations/${id}/undo`, {
+      method: "POST",
+    }),
   updateItemFavorite: (id: string, payload: { favorite: boolean }) =>
     request<{ status: string }>(`/items/${id}/favorite`, {
       method: "PATCH",
@@ -100,4 +127,8 @@ export const api = {
     if (params.feed_id != null) query.set("feed_id", String(params.feed_id));
     return request<{ unread: number }>(`/items/unread-count?${query.toString()}`);
   },
+  counters: () => request<Counters>("/counters"),
+  feedStats: (id: string, days?: number) =>
+    request<FeedStats>(`/feeds/${id}/stats${days ? `?days=${days}` : ""}`),
+  listFeedStats: (days?: number) => request<FeedStats[]>(`/stats/feeds${days ? `?days=${days}` : ""}`),
 };
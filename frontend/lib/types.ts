@@ -1,6 +1,7 @@
 export type Category = {
   id: string;
   name: string;
+  parent_id: string | null;
   created_at: string;
 };
 
@@ -8,11 +9,13 @@ export type Feed = {
   id: string;
   name: string;
   url: string;
+  language?: string | null;
   category_id: string | null;
   fetch_interval_minutes: number;
   last_fetched_at: string | null;
   last_status: string | null;
   last_error: string | null;
+  last_warning: string | null;
   category_name?: string | null;
 };
 
@@ -28,18 +31,45 @@ export type Item = {
   published_at: string | null;
   is_read: boolean;
   is_favorite: boolean;
+  tags: string[];
+  rank?: number;
+  snippet?: string;
+};
+
+export type ItemSort = "newest" | "oldest" | "feed" | "fetched" | "relevance";
+
+export type ItemDetail = Item & {
+  content: string | null;
+  author: string | null;
+  enclosures: { url: string; type?: string; length?: number }[];
+  feed_site_url: string | null;
+  fetched_at: string;
+  read_later_at: string | null;
+  matches_filter: boolean;
+  prev_id: string | null;
+  next_id: string | null;
 };
 
 export type ItemsResponse = {
   items: Item[];
-  total: number;
-  page: number;
+  total: number | null;
+  page?: number;
   page_size: number;
+  next_cursor: string | null;
+  prev_cursor: string | null;
+};
+
+export type ReadOperation = {
+  updated: number;
+  operation_id: string | null;
+  undo_expires_at: string | null;
 };
 
 export type TransferCategory = {
   id: string;
   name: string;
+  parent_id?: string;
+  parent_name?: string;
 };
 
 export type TransferFeed = {
@@ -54,3 +84,43 @@ export type TransferPayload = {
   categories: TransferCategory[];
   feeds: TransferFeed[];
 };
+
+export type Counter = {
+  unread: number;
+  starred: number;
+  total: number;
+};
+
+export type Counters = {
+  totals: Counter;
+  feeds: (Counter & { feed_id: string })[];
+  categories: (Counter & { category_id: string })[];
+};
+
+export type FeedStatsBucket = {
+  start: string;
+  items: number;
+};
+
+export type FeedStats = {
+  feed_id: string;
+  feed_name: string;
+  window_days: number;
+  items: number;
+  window_items: number;
+  items_per_day: number;
+  items_per_week: number;
+  avg_hours_between_posts: number | null;
+  last_item_at: string | null;
+  hours_since_last_item: number | null;
+  read_ratio: number;
+  favorite_ratio: number;
+  open_ratio: number;
+  clicks: number;
+  fetch_count: number;
+  fetch_errors: number;
+  fetch_success_rate: number | null;
+  last_fetched_at: string | null;
+  daily?: FeedStatsBucket[];
+  weekly?: FeedStatsBucket[];
+};